@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func diffMain(args []string) {
+	diffCommand := flag.NewFlagSet("diff", flag.ExitOnError)
+	diffCommand.SetOutput(os.Stdout)
+	meqaPath := diffCommand.String("d", meqaDataDir, "the directory where meqa config, log and output files reside")
+	jsonOutput := diffCommand.Bool("json", false, "print the changes in json")
+	verbose := diffCommand.Bool("v", false, "turn on verbose mode")
+	diffCommand.Usage = func() {
+		fmt.Println("Usage: mqgen diff [options] old.yml new.yml")
+		fmt.Println("\nreport the changes between two versions of the swagger spec. Exits with 1 if any change is breaking.")
+		diffCommand.PrintDefaults()
+	}
+	diffCommand.Parse(args)
+	if diffCommand.NArg() != 2 {
+		diffCommand.Usage()
+		os.Exit(2)
+	}
+	oldSwaggerFile := diffCommand.Arg(0)
+	newSwaggerFile := diffCommand.Arg(1)
+
+	breaking, err := diff(meqaPath, &oldSwaggerFile, &newSwaggerFile, jsonOutput, verbose)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(2)
+	}
+	if breaking {
+		os.Exit(1)
+	}
+}
+
+// diff prints the changes between the two specs and returns whether any of them is breaking.
+func diff(meqaPath *string, oldSwaggerFile *string, newSwaggerFile *string, jsonOutput *bool, verbose *bool) (bool, error) {
+	mqutil.Verbose = *verbose
+
+	var specs []*mqswag.Swagger
+	for _, swaggerFile := range []string{*oldSwaggerFile, *newSwaggerFile} {
+		if fi, err := os.Stat(swaggerFile); os.IsNotExist(err) || fi.Mode().IsDir() {
+			return false, fmt.Errorf("can't load swagger file at the following location %s", swaggerFile)
+		}
+		swagger, err := mqswag.CreateSwaggerFromURL(swaggerFile, *meqaPath)
+		if err != nil {
+			return false, err
+		}
+		specs = append(specs, swagger)
+	}
+	specDiff := mqswag.DiffSpecs(specs[0], specs[1])
+	breaking := specDiff.Breaking()
+
+	if *jsonOutput {
+		diffBytes, err := mqutil.MarshalJsonIndentNoEscape(specDiff)
+		if err != nil {
+			return false, err
+		}
+		fmt.Println(string(diffBytes))
+		return len(breaking) > 0, nil
+	}
+
+	if len(specDiff.Changes) == 0 {
+		fmt.Println("No changes found.")
+		return false, nil
+	}
+	if len(breaking) > 0 {
+		fmt.Println("Breaking changes:")
+		for _, c := range breaking {
+			fmt.Printf("\t%s\n", c.ToString())
+		}
+	}
+	if nonBreaking := specDiff.NonBreaking(); len(nonBreaking) > 0 {
+		fmt.Println("Non-breaking changes:")
+		for _, c := range nonBreaking {
+			fmt.Printf("\t%s\n", c.ToString())
+		}
+	}
+	fmt.Printf("%d changes, %d breaking\n", len(specDiff.Changes), len(breaking))
+	return len(breaking) > 0, nil
+}
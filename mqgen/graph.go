@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func graphMain(args []string) {
+	graphCommand := flag.NewFlagSet("graph", flag.ExitOnError)
+	graphCommand.SetOutput(os.Stdout)
+	meqaPath := graphCommand.String("d", meqaDataDir, "the directory where meqa config, log and output files reside")
+	swaggerFile := graphCommand.String("s", filepath.Join(meqaDataDir, "swagger.yml"), "the swagger.yml file location")
+	format := graphCommand.String("format", mqswag.GraphFormatDot, "the output format - dot, mermaid, json")
+	focus := graphCommand.String("focus", "", "only export the graph around this definition (e.g. Pet) or operation (e.g. \"post /pet\" or its operationId)")
+	outputFile := graphCommand.String("o", "", "the output file, default to stdout")
+	verbose := graphCommand.Bool("v", false, "turn on verbose mode")
+	graphCommand.Parse(args)
+	// The graph may be written to stdout, keep the logs out of it.
+	mqutil.Logger = mqutil.NewLogger(os.Stderr)
+
+	err := graph(meqaPath, swaggerFile, format, focus, outputFile, verbose)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func graph(meqaPath *string, swaggerFile *string, format *string, focus *string, outputFile *string, verbose *bool) error {
+	mqutil.Verbose = *verbose
+
+	if fi, err := os.Stat(*swaggerFile); os.IsNotExist(err) || fi.Mode().IsDir() {
+		return fmt.Errorf("can't load swagger file at the following location %s", *swaggerFile)
+	}
+	_, dag, err := loadDAG(*swaggerFile, *meqaPath)
+	if err != nil {
+		return err
+	}
+	printBrokenEdges(os.Stderr, dag)
+	g, err := dag.Export(*focus)
+	if err != nil {
+		return err
+	}
+	output, err := g.Format(*format)
+	if err != nil {
+		return err
+	}
+	if len(*outputFile) == 0 {
+		fmt.Print(output)
+		return nil
+	}
+	err = ioutil.WriteFile(*outputFile, []byte(output), 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Graph exported to:", *outputFile)
+	return nil
+}
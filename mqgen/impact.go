@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gbatanov/meqa/mqplan"
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func impactMain(args []string) {
+	impactCommand := flag.NewFlagSet("impact", flag.ExitOnError)
+	impactCommand.SetOutput(os.Stdout)
+	meqaPath := impactCommand.String("d", meqaDataDir, "the directory where meqa config, log and output files reside")
+	swaggerFile := impactCommand.String("s", filepath.Join(meqaDataDir, "swagger.yml"), "the swagger.yml file location")
+	oldSwaggerFile := impactCommand.String("old", "", "the previous version of the swagger.yml, the changes from it are analyzed")
+	changed := impactCommand.String("c", "", "the comma separated list of changed definitions (e.g. Pet) or operations (e.g. \"post /pet\" or its operationId)")
+	testPlanFile := impactCommand.String("p", "", "the test plan file to filter down to the affected tests")
+	outputFile := impactCommand.String("o", "", "the filtered test plan file name (default impact.yml in meqa_data dir)")
+	verbose := impactCommand.Bool("v", false, "turn on verbose mode")
+	impactCommand.Parse(args)
+
+	err := impact(meqaPath, swaggerFile, oldSwaggerFile, changed, testPlanFile, outputFile, verbose)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func impact(meqaPath *string, swaggerFile *string, oldSwaggerFile *string, changed *string, testPlanFile *string,
+	outputFile *string, verbose *bool) error {
+
+	mqutil.Verbose = *verbose
+
+	if fi, err := os.Stat(*swaggerFile); os.IsNotExist(err) || fi.Mode().IsDir() {
+		return fmt.Errorf("can't load swagger file at the following location %s", *swaggerFile)
+	}
+	swagger, dag, err := loadDAG(*swaggerFile, *meqaPath)
+	if err != nil {
+		return err
+	}
+	printBrokenEdges(os.Stdout, dag)
+
+	var changedList []string
+	for _, c := range strings.Split(*changed, ",") {
+		if c = strings.TrimSpace(c); len(c) > 0 {
+			changedList = append(changedList, c)
+		}
+	}
+	if len(*oldSwaggerFile) > 0 {
+		oldSwagger, err := mqswag.CreateSwaggerFromURL(*oldSwaggerFile, *meqaPath)
+		if err != nil {
+			return err
+		}
+		changedList = append(changedList, mqswag.ChangedItems(oldSwagger, swagger)...)
+	}
+	if len(changedList) == 0 {
+		return fmt.Errorf("nothing changed. Use -c to list the changes or -old to compare with the previous spec")
+	}
+	fmt.Println("Changed:")
+	for _, c := range changedList {
+		fmt.Printf("\t%s\n", c)
+	}
+
+	affected, unmatched := dag.AffectedOperations(changedList)
+	operations := make(map[string]bool)
+	for _, name := range unmatched {
+		// The operations removed from the spec are still in the old test plans.
+		if fields := strings.Fields(name); len(fields) == 2 {
+			operations[mqswag.OperationKey(fields[0], fields[1])] = true
+		} else {
+			fmt.Printf("Warning: %s is not found in the spec\n", name)
+		}
+	}
+	fmt.Println("Affected operations:")
+	for _, n := range affected {
+		operations[mqswag.OperationKey(n.GetMethod(), n.GetName())] = true
+		fmt.Printf("\t%s\n", mqswag.OperationKey(n.GetMethod(), n.GetName()))
+	}
+
+	if len(*testPlanFile) == 0 {
+		return nil
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+	plan := &mqplan.TestPlan{}
+	err = plan.InitFromFile(*testPlanFile, db)
+	if err != nil {
+		return err
+	}
+	filtered := plan.FilterByOperations(operations)
+	fmt.Println("Affected tests:")
+	for _, suite := range filtered.SuiteList {
+		if suite.Name == mqplan.MeqaInit {
+			continue
+		}
+		for _, t := range suite.Tests {
+			fmt.Printf("\t%s: %s\n", suite.Name, t.Name)
+		}
+	}
+	if len(*outputFile) == 0 {
+		*outputFile = filepath.Join(*meqaPath, "impact.yml")
+	}
+	err = filtered.DumpToFile(*outputFile)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Test plans generated at:", *outputFile)
+	return nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 
 	"os"
 	"path/filepath"
@@ -13,38 +14,62 @@ import (
 )
 
 const (
-	meqaDataDir = "meqa_data"
-	algoSimple  = "simple"
-	algoObject  = "object"
-	algoPath    = "path"
-	algoAll     = "all"
+	meqaDataDir   = "meqa_data"
+	algoSimple    = "simple"
+	algoObject    = "object"
+	algoPath      = "path"
+	algoRandom    = "random"
+	algoLifecycle = "lifecycle"
+	algoPairwise  = "pairwise"
+	algoAll       = "all"
 )
 
-var algoList []string = []string{algoSimple, algoObject, algoPath}
+var algoList []string = []string{algoSimple, algoObject, algoPath, algoRandom, algoLifecycle, algoPairwise}
 
 func main() {
 	mqutil.Logger = mqutil.NewStdLogger()
 
-	swaggerJSONFile := filepath.Join(meqaDataDir, "swagger.yml")
-	meqaPath := flag.String("d", meqaDataDir, "the directory where we put the generated files")
-	swaggerFile := flag.String("s", swaggerJSONFile, "the swagger.yml file location")
-	algorithm := flag.String("a", "all", "the algorithm - simple, object, path, all")
-	verbose := flag.Bool("v", false, "turn on verbose mode")
-	whitelistFile := flag.String("w", "", "the whitelist.txt file location")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "graph":
+			graphMain(os.Args[2:])
+			return
+		case "impact":
+			impactMain(os.Args[2:])
+			return
+		case "diff":
+			diffMain(os.Args[2:])
+			return
+		}
+	}
 
+	o := &generateOptions{}
+	o.registerFlags(flag.CommandLine)
+
+	flag.Usage = func() {
+		fmt.Println("Usage: mqgen [options]")
+		fmt.Println("       mqgen graph [options]")
+		fmt.Println("       mqgen impact [options]")
+		fmt.Println("       mqgen diff [options] old.yml new.yml")
+		fmt.Println("\ngenerate the test plans:")
+		flag.PrintDefaults()
+		fmt.Println("\ngraph: export the dependency graph, use mqgen graph -h to see the options")
+		fmt.Println("impact: find the operations and tests affected by a change, use mqgen impact -h to see the options")
+		fmt.Println("diff: report the changes between two versions of the spec and whether they are breaking")
+	}
 	flag.Parse()
-	run(meqaPath, swaggerFile, algorithm, verbose, whitelistFile)
+	run(o)
 }
 
-func run(meqaPath *string, swaggerFile *string, algorithm *string, verbose *bool, whitelistFile *string) {
-	mqutil.Verbose = *verbose
+func run(o *generateOptions) {
+	mqutil.Verbose = o.verbose
 
-	swaggerJsonPath := *swaggerFile
+	swaggerJsonPath := o.swaggerFile
 	if fi, err := os.Stat(swaggerJsonPath); os.IsNotExist(err) || fi.Mode().IsDir() {
 		fmt.Printf("Can't load swagger file at the following location %s", swaggerJsonPath)
 		os.Exit(1)
 	}
-	whitelistPath := *whitelistFile
+	whitelistPath := o.whitelistFile
 	var whitelist map[string]bool
 	if len(whitelistPath) > 0 {
 		if fi, err := os.Stat(whitelistPath); os.IsNotExist(err) || fi.Mode().IsDir() {
@@ -58,7 +83,7 @@ func run(meqaPath *string, swaggerFile *string, algorithm *string, verbose *bool
 			os.Exit(1)
 		}
 	}
-	testPlanPath := *meqaPath
+	testPlanPath := o.meqaPath
 	if fi, err := os.Stat(testPlanPath); os.IsNotExist(err) {
 		err = os.Mkdir(testPlanPath, 0755)
 		if err != nil {
@@ -70,45 +95,53 @@ func run(meqaPath *string, swaggerFile *string, algorithm *string, verbose *bool
 		os.Exit(1)
 	}
 
-	// loading swagger.json
-	swagger, err := mqswag.CreateSwaggerFromURL(swaggerJsonPath, *meqaPath)
+	swagger, dag, err := loadDAG(swaggerJsonPath, o.meqaPath)
 	if err != nil {
 		mqutil.Logger.Printf("Error: %s", err.Error())
 		os.Exit(1)
 	}
-	dag := mqswag.NewDAG()
-	err = swagger.AddToDAG(dag)
-	if err != nil {
-		mqutil.Logger.Printf("Error: %s", err.Error())
-		os.Exit(1)
-	}
-
-	dag.Sort()
-	dag.CheckWeight()
+	printBrokenEdges(os.Stdout, dag)
 
 	var plansToGenerate []string
-	if *algorithm == algoAll {
+	if o.algorithm == algoAll {
 		plansToGenerate = algoList
 	} else {
-		plansToGenerate = append(plansToGenerate, *algorithm)
+		plansToGenerate = append(plansToGenerate, o.algorithm)
 	}
 
 	for _, algo := range plansToGenerate {
 		var testPlan *mqplan.TestPlan
 		switch algo {
 		case algoPath:
-			testPlan, err = mqplan.GeneratePathTestPlan(swagger, dag, whitelist)
+			testPlan, err = mqplan.GeneratePathTestPlan(swagger, dag, whitelist, &o.filter)
+		case algoRandom:
+			testPlan, err = mqplan.GenerateRandomTestPlan(swagger, dag, &o.filter, o.count, o.length, o.seed)
+		case algoPairwise:
+			testPlan, err = mqplan.GeneratePairwiseTestPlan(swagger, dag, &o.filter, o.pairwiseCap)
+		case algoLifecycle:
+			testPlan, err = mqplan.GenerateLifecycleTestPlan(swagger, dag, &o.filter)
 		case algoObject:
-			testPlan, err = mqplan.GenerateTestPlan(swagger, dag)
+			testPlan, err = mqplan.GenerateTestPlan(swagger, dag, &o.filter)
 		default:
-			testPlan, err = mqplan.GenerateSimpleTestPlan(swagger, dag)
+			testPlan, err = mqplan.GenerateSimpleTestPlan(swagger, dag, &o.filter, o.size, o.strategy, o.seed)
 		}
 		if err != nil {
 			mqutil.Logger.Printf("Error: %s", err.Error())
 			os.Exit(1)
 		}
 		testPlanFile := filepath.Join(testPlanPath, algo+".yml")
+		generated := testPlan
+		if o.merge {
+			testPlan, err = mergePlan(generated, testPlanFile, swagger)
+			if err != nil {
+				mqutil.Logger.Printf("Error: %s", err.Error())
+				os.Exit(1)
+			}
+		}
 		err = testPlan.DumpToFile(testPlanFile)
+		if err == nil {
+			err = generated.DumpToFile(baseFileName(testPlanFile))
+		}
 		if err != nil {
 			mqutil.Logger.Printf("Error: %s", err.Error())
 			os.Exit(1)
@@ -116,3 +149,34 @@ func run(meqaPath *string, swaggerFile *string, algorithm *string, verbose *bool
 		fmt.Println("Test plans generated at:", testPlanFile)
 	}
 }
+
+// printBrokenEdges warns about the dependencies ignored because of circular dependencies.
+func printBrokenEdges(w io.Writer, dag *mqswag.DAG) {
+	if len(dag.BrokenEdges) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Warning: circular dependencies found. The following dependencies are ignored when ordering the tests:")
+	for _, e := range dag.BrokenEdges {
+		fmt.Fprintf(w, "\t%s\n", e.ToString())
+	}
+}
+
+// loadDAG loads the swagger spec and builds the dependency graph of its definitions and operations.
+func loadDAG(swaggerPath string, meqaPath string) (*mqswag.Swagger, *mqswag.DAG, error) {
+	swagger, err := mqswag.CreateSwaggerFromURL(swaggerPath, meqaPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	dag := mqswag.NewDAG()
+	err = swagger.AddToDAG(dag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dag.Sort()
+	err = dag.CheckWeight()
+	if err != nil {
+		return nil, nil, err
+	}
+	return swagger, dag, nil
+}
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/gbatanov/meqa/mqplan"
 	"github.com/gbatanov/meqa/mqutil"
 )
 
@@ -13,10 +14,17 @@ func TestMqgen(t *testing.T) {
 	wd, _ := os.Getwd()
 	meqaPath := filepath.Join(wd, "../../../testdata")
 	swaggerPath := filepath.Join(meqaPath, "petstore_meqa.yml")
-	algorithm := "all"
-	verbose := false
-	whitelistFile := ""
-	run(&meqaPath, &swaggerPath, &algorithm, &verbose, &whitelistFile)
+	o := &generateOptions{
+		meqaPath:    meqaPath,
+		swaggerFile: swaggerPath,
+		algorithm:   "all",
+		size:        mqplan.DefaultSimpleSize,
+		strategy:    mqplan.SimpleByWeight,
+		count:       mqplan.DefaultRandomCount,
+		length:      mqplan.DefaultRandomLength,
+		pairwiseCap: mqplan.DefaultPairwiseCap,
+	}
+	run(o)
 }
 
 func TestMain(m *testing.M) {
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gbatanov/meqa/mqplan"
+	"github.com/gbatanov/meqa/mqswag"
+)
+
+// baseFileName is where the generated plan is kept as it was generated, so that the next merge can
+// tell the tests edited by hand from the ones that were not.
+func baseFileName(testPlanFile string) string {
+	return filepath.Join(filepath.Dir(testPlanFile), "."+filepath.Base(testPlanFile))
+}
+
+// mergePlan merges the generated plan into the test plan file, if there is one.
+func mergePlan(generated *mqplan.TestPlan, testPlanFile string, swagger *mqswag.Swagger) (*mqplan.TestPlan, error) {
+	if _, err := os.Stat(testPlanFile); os.IsNotExist(err) {
+		return generated, nil
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+	existing := &mqplan.TestPlan{}
+	err := existing.InitFromFile(testPlanFile, db)
+	if err != nil {
+		return nil, err
+	}
+	var base *mqplan.TestPlan
+	if _, err := os.Stat(baseFileName(testPlanFile)); err == nil {
+		base = &mqplan.TestPlan{}
+		err = base.InitFromFile(baseFileName(testPlanFile), db)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		fmt.Printf("Warning: %s not found, all the existing tests are taken as edited by hand\n", baseFileName(testPlanFile))
+	}
+
+	merged, report := mqplan.MergePlans(existing, base, generated)
+	fmt.Printf("Merged into %s: %d added, %d updated, %d kept, %d obsolete\n", testPlanFile,
+		len(report.Added), len(report.Updated), len(report.Kept), len(report.Obsolete))
+	printTests := func(title string, tests []string) {
+		if len(tests) == 0 {
+			return
+		}
+		fmt.Println(title)
+		for _, t := range tests {
+			fmt.Printf("\t%s\n", t)
+		}
+	}
+	printTests("Added:", report.Added)
+	printTests("Kept as edited:", report.Kept)
+	printTests("Obsolete, the operations are no longer in the spec:", report.Obsolete)
+	return merged, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+
+	"github.com/gbatanov/meqa/mqplan"
+	"github.com/gbatanov/meqa/mqswag"
+)
+
+// generateOptions are the settings of the test plan generation, filled from the flags.
+type generateOptions struct {
+	meqaPath      string
+	swaggerFile   string
+	algorithm     string
+	verbose       bool
+	whitelistFile string
+	merge         bool
+	filter        mqswag.OperationFilter
+
+	size        int
+	strategy    string
+	seed        int64
+	count       int
+	length      int
+	pairwiseCap int
+}
+
+func (o *generateOptions) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.meqaPath, "d", meqaDataDir, "the directory where we put the generated files")
+	fs.StringVar(&o.swaggerFile, "s", filepath.Join(meqaDataDir, "swagger.yml"), "the swagger.yml file location")
+	fs.StringVar(&o.algorithm, "a", "all", "the algorithm - simple, object, path, random, lifecycle, pairwise, all")
+	fs.BoolVar(&o.verbose, "v", false, "turn on verbose mode")
+	fs.StringVar(&o.whitelistFile, "w", "", "the whitelist.txt file location")
+	fs.BoolVar(&o.merge, "merge", false, "merge into the existing test plans, keeping the tests edited by hand")
+	o.filter.RegisterFlags(fs)
+	fs.IntVar(&o.size, "size", mqplan.DefaultSimpleSize, "the number of operations the simple algorithm samples, 0 for all")
+	fs.StringVar(&o.strategy, "strategy", mqplan.SimpleByWeight, "how the simple algorithm samples the operations - "+
+		strings.Join(mqplan.SimpleStrategies, ", "))
+	fs.Int64Var(&o.seed, "seed", 0, "the seed for the random sampling and the random algorithm, 0 for a new one every time")
+	fs.IntVar(&o.count, "count", mqplan.DefaultRandomCount, "the number of call sequences the random algorithm generates")
+	fs.IntVar(&o.length, "length", mqplan.DefaultRandomLength, "the number of calls in each sequence of the random algorithm")
+	fs.IntVar(&o.pairwiseCap, "cap", mqplan.DefaultPairwiseCap, "the maximum number of combinations the pairwise algorithm tests per operation, 0 for no limit")
+}
@@ -8,12 +8,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 
 	"os"
 	"strings"
 
 	"path/filepath"
 
+	"github.com/gbatanov/meqa/mqmock"
 	"github.com/gbatanov/meqa/mqplan"
 	"github.com/gbatanov/meqa/mqswag"
 	"github.com/gbatanov/meqa/mqutil"
@@ -215,27 +217,31 @@ func main() {
 	genCommand.SetOutput(os.Stdout)
 	runCommand := flag.NewFlagSet("run", flag.ExitOnError)
 	runCommand.SetOutput(os.Stdout)
+	mockCommand := flag.NewFlagSet("mock", flag.ExitOnError)
+	mockCommand.SetOutput(os.Stdout)
 
 	genMeqaPath := genCommand.String("d", meqaDataDir, "the directory where meqa config, log and output files reside")
 	genSwaggerFile := genCommand.String("s", "", "the OpenAPI (Swagger) spec file path")
 
-	runMeqaPath := runCommand.String("d", meqaDataDir, "the directory where meqa config, log and output files reside")
-	runSwaggerFile := runCommand.String("s", "", "the meqa generated OpenAPI (Swagger) spec file path")
-	testPlanFile := runCommand.String("p", "", "the test plan file name")
-	resultPath := runCommand.String("r", "result.yml", "the test result file name (default result.yml in meqa_data dir)")
-	testToRun := runCommand.String("t", "all", "the test to run")
-	username := runCommand.String("u", "", "the username for basic HTTP authentication")
-	password := runCommand.String("w", "", "the password for basic HTTP authentication")
-	apitoken := runCommand.String("a", "", "the api token for bearer HTTP authentication")
-	verbose := runCommand.Bool("v", false, "turn on verbose mode")
+	runOpts := &runOptions{}
+	runOpts.registerFlags(runCommand)
+
+	mockMeqaPath := mockCommand.String("d", meqaDataDir, "the directory where meqa config, log and output files reside")
+	mockSwaggerFile := mockCommand.String("s", "", "the meqa generated OpenAPI (Swagger) spec file path")
+	mockAddr := mockCommand.String("addr", ":8080", "the address the mock server listens on")
+	stateful := mockCommand.Bool("stateful", false, "keep the posted objects and return them in the later requests")
+	mockVerbose := mockCommand.Bool("v", false, "turn on verbose mode")
 
 	flag.Usage = func() {
-		fmt.Println("Usage: mqgo {generate|run} [options]")
+		fmt.Println("Usage: mqgo {generate|run|mock} [options]")
 		fmt.Println("generate: generate test plans to be used by run command")
 		genCommand.PrintDefaults()
 
 		fmt.Println("\nrun: run the tests the in a test plan file")
 		runCommand.PrintDefaults()
+
+		fmt.Println("\nmock: serve a mock of the REST service described by the spec")
+		mockCommand.PrintDefaults()
 	}
 
 	if len(os.Args) < 2 {
@@ -251,9 +257,13 @@ func main() {
 		meqaPath = genMeqaPath
 		swaggerFile = genSwaggerFile
 	case "run":
-		runCommand.Parse(os.Args[2:])
-		meqaPath = runMeqaPath
-		swaggerFile = runSwaggerFile
+		runOpts.parse(runCommand, os.Args[2:])
+		meqaPath = &runOpts.meqaPath
+		swaggerFile = &runOpts.swaggerFile
+	case "mock":
+		mockCommand.Parse(os.Args[2:])
+		meqaPath = mockMeqaPath
+		swaggerFile = mockSwaggerFile
 	default:
 		flag.Usage()
 		os.Exit(1)
@@ -274,9 +284,8 @@ func main() {
 	}
 
 	if os.Args[1] == "run" {
-		if len(*resultPath) == 0 {
-			rf := filepath.Join(*meqaPath, resultFile)
-			resultPath = &rf
+		if len(runOpts.resultPath) == 0 {
+			runOpts.resultPath = filepath.Join(*meqaPath, resultFile)
 		}
 	}
 
@@ -297,47 +306,163 @@ func main() {
 		return
 	}
 
-	runMeqa(meqaPath, swaggerFile, testPlanFile, resultPath, testToRun, username, password, apitoken, verbose)
+	if mockCommand.Parsed() {
+		runMock(meqaPath, swaggerFile, mockAddr, stateful, mockVerbose)
+		return
+	}
+
+	runMeqa(runOpts)
 }
 
-func runMeqa(meqaPath *string, swaggerFile *string, testPlanFile *string, resultPath *string,
-	testToRun *string, username *string, password *string, apitoken *string, verbose *bool) {
+// overridePlan applies the request settings given on the command line, the ones in setFlags, to the plan.
+func overridePlan(plan *mqplan.TestPlan, o *runOptions) {
+	if o.setFlags["timeout"] {
+		plan.Timeout = o.timeout
+	}
+	if o.setFlags["retries"] || o.setFlags["backoff"] {
+		retry := mqplan.Retry{}
+		if plan.Retry != nil {
+			retry = *plan.Retry
+		}
+		if o.setFlags["retries"] {
+			retry.Count = o.retries
+		}
+		if o.setFlags["backoff"] {
+			retry.Backoff = o.backoff
+		}
+		plan.Retry = &retry
+	}
+	if o.setFlags["rps"] {
+		plan.RPS = o.rps
+	}
+	if o.setFlags["max-in-flight"] {
+		plan.MaxInFlight = o.maxInFlight
+	}
+	if o.setFlags["ca"] || o.setFlags["cert"] || o.setFlags["key"] || o.setFlags["server-name"] || o.setFlags["insecure"] {
+		settings := mqplan.TLS{}
+		if plan.TLS != nil {
+			settings = *plan.TLS
+		}
+		if o.setFlags["ca"] {
+			settings.CA = o.caFile
+		}
+		if o.setFlags["cert"] {
+			settings.Cert = o.certFile
+		}
+		if o.setFlags["key"] {
+			settings.Key = o.keyFile
+		}
+		if o.setFlags["server-name"] {
+			settings.ServerName = o.serverName
+		}
+		if o.setFlags["insecure"] {
+			settings.Insecure = o.insecure
+		}
+		plan.TLS = &settings
+	}
+}
 
-	mqutil.Verbose = *verbose
+func runMeqa(o *runOptions) {
 
-	if len(*testPlanFile) == 0 {
+	mqutil.Verbose = o.verbose
+
+	if len(o.testPlanFile) == 0 {
 		fmt.Println("You must use -p to specify a test plan file. Use -h to see more options.")
 		return
 	}
 
-	if _, err := os.Stat(*testPlanFile); os.IsNotExist(err) {
-		fmt.Printf("can't load test plan file at the following location %s", *testPlanFile)
+	if _, err := os.Stat(o.testPlanFile); os.IsNotExist(err) {
+		fmt.Printf("can't load test plan file at the following location %s", o.testPlanFile)
 		return
 	}
 
 	// load swagger.yml
-	swagger, err := mqswag.CreateSwaggerFromURL(*swaggerFile, *meqaPath)
+	swagger, err := mqswag.CreateSwaggerFromURL(o.swaggerFile, o.meqaPath)
 	if err != nil {
 		mqutil.Logger.Printf("Error: %s", err.Error())
 	}
 	mqswag.ObjDB.Init(swagger)
+	if len(o.dbIn) > 0 {
+		if err = mqswag.ObjDB.LoadFromFile(o.dbIn); err != nil {
+			fmt.Printf("can't load the objects at %s: %s\n", o.dbIn, err.Error())
+			return
+		}
+	}
+	for _, fixtureFile := range o.fixtures {
+		if err = mqswag.ObjDB.LoadFixtures(fixtureFile); err != nil {
+			fmt.Printf("can't load the fixtures: %s\n", err.Error())
+			return
+		}
+	}
 
 	// load test plan
-	mqplan.Current.Username = *username
-	mqplan.Current.Password = *password
-	mqplan.Current.ApiToken = *apitoken
-	err = mqplan.Current.InitFromFile(*testPlanFile, &mqswag.ObjDB)
+	mqplan.Current.Username = o.username
+	mqplan.Current.Password = o.password
+	mqplan.Current.ApiToken = o.apiToken
+	mqplan.Current.ShowSecrets = o.showSecrets
+	mqplan.Current.Filter = &o.filter
+	mqplan.Current.SharedDB = len(o.dbIn) > 0 || len(o.dbOut) > 0 || len(o.fixtures) > 0
+	if len(o.harFile) > 0 {
+		mqplan.Current.Recorder = mqplan.NewHarRecorder()
+	}
+	mqplan.Current.DBDumper, err = mqplan.NewDBDumper(o.dbDump, filepath.Join(o.meqaPath, "dbdump"))
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return
+	}
+	if o.cleanup != mqplan.CleanupNone {
+		dag := mqswag.NewDAG()
+		if err = swagger.AddToDAG(dag); err != nil {
+			fmt.Printf("can't find the delete operations for the cleanup: %s\n", err.Error())
+			return
+		}
+		dag.Sort()
+		mqplan.Current.Cleaner, err = mqplan.NewCleaner(o.cleanup, swagger, dag)
+		if err != nil {
+			fmt.Printf("%s\n", err.Error())
+			return
+		}
+	}
+	err = mqplan.Current.InitFromFile(o.testPlanFile, &mqswag.ObjDB)
 	if err != nil {
 		mqutil.Logger.Printf("Error loading test plan: %s", err.Error())
 	}
+	// The command line settings override the ones of the plan's meqa_init.
+	overridePlan(&mqplan.Current, o)
+	mqplan.Current.Limiter, err = mqplan.NewLimiter(mqplan.Current.RPS, mqplan.Current.MaxInFlight)
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return
+	}
 
-	// for testing, set the config to skip verifying https certificates
-	resty.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+	// The TLS config goes on the default transport, so it has to be set before the replayer replaces it.
+	settings := mqplan.TLS{}
+	if mqplan.Current.TLS != nil {
+		settings = *mqplan.Current.TLS
+	}
+	tlsClientConfig, err := tlsConfig(settings.CA, settings.Cert, settings.Key, settings.ServerName, settings.Insecure)
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return
+	}
+	resty.SetTLSClientConfig(tlsClientConfig)
 	resty.SetRedirectPolicy(resty.FlexibleRedirectPolicy(15))
 
+	if len(o.replayFile) > 0 {
+		replayer, err := mqplan.LoadReplayerFromFile(o.replayFile)
+		if err != nil {
+			fmt.Printf("can't load the recorded traffic at %s: %s\n", o.replayFile, err.Error())
+			return
+		}
+		resty.SetTransport(replayer)
+	}
+
 	mqplan.Current.ResultCounts = make(map[string]int)
-	if *testToRun == "all" {
+	if o.testToRun == "all" {
 		for _, testSuite := range mqplan.Current.SuiteList {
+			if !mqplan.Current.SuiteSelected(testSuite) {
+				continue
+			}
 			mqutil.Logger.Printf("\n---\nTest suite: %s\n", testSuite.Name)
 			fmt.Printf("\n---\nTest suite: %s\n", testSuite.Name)
 			counts, err := mqplan.Current.Run(testSuite.Name, nil)
@@ -345,18 +470,59 @@ func runMeqa(meqaPath *string, swaggerFile *string, testPlanFile *string, result
 			for k := range counts {
 				mqplan.Current.ResultCounts[k] += counts[k]
 			}
+			if mqplan.Current.Cleaner != nil && mqplan.Current.Cleaner.PerSuite {
+				mqplan.Current.CleanUp()
+			}
 		}
 	} else {
-		mqutil.Logger.Printf("\n---\nTest suite: %s\n", *testToRun)
-		fmt.Printf("\n---\nTest suite: %s\n", *testToRun)
-		counts, err := mqplan.Current.Run(*testToRun, nil)
+		mqutil.Logger.Printf("\n---\nTest suite: %s\n", o.testToRun)
+		fmt.Printf("\n---\nTest suite: %s\n", o.testToRun)
+		counts, err := mqplan.Current.Run(o.testToRun, nil)
 		mqutil.Logger.Printf("err:\n%v", err)
 		for k := range counts {
 			mqplan.Current.ResultCounts[k] += counts[k]
 		}
 	}
+	if mqplan.Current.Cleaner != nil {
+		fmt.Printf("\n---\nCleanup\n")
+		mqplan.Current.CleanUp()
+	}
 	mqplan.Current.LogErrors()
 	mqplan.Current.PrintSummary()
-	os.Remove(*resultPath)
-	mqplan.Current.WriteResultToFile(*resultPath)
+	mqplan.Current.PrintCleanupReport()
+	os.Remove(o.resultPath)
+	mqplan.Current.WriteResultToFile(o.resultPath)
+	if mqplan.Current.Recorder != nil {
+		err = mqplan.Current.Recorder.WriteToFile(o.harFile)
+		if err != nil {
+			mqutil.Logger.Printf("Error writing HAR file: %s", err.Error())
+		}
+	}
+	if len(o.dbOut) > 0 {
+		err = mqswag.ObjDB.WriteToFile(o.dbOut)
+		if err != nil {
+			fmt.Printf("Error saving the objects: %s\n", err.Error())
+		}
+	}
+}
+
+func runMock(meqaPath *string, swaggerFile *string, addr *string, stateful *bool, verbose *bool) {
+	mqutil.Verbose = *verbose
+
+	swagger, err := mqswag.CreateSwaggerFromURL(*swaggerFile, *meqaPath)
+	if err != nil {
+		fmt.Printf("can't load swagger file %s: %s\n", *swaggerFile, err.Error())
+		os.Exit(1)
+	}
+	server, err := mqmock.NewServer(swagger, *stateful)
+	if err != nil {
+		fmt.Printf("can't create the mock server: %s\n", err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("Mock server listening on %s\n", *addr)
+	err = http.ListenAndServe(*addr, server)
+	if err != nil {
+		fmt.Printf("mock server stopped: %s\n", err.Error())
+		os.Exit(1)
+	}
 }
@@ -1,27 +1,111 @@
 package main
 
 import (
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/gbatanov/meqa/mqplan"
 	"github.com/gbatanov/meqa/mqutil"
+	"gopkg.in/resty.v1"
 )
 
 func TestMqgo(t *testing.T) {
 	wd, _ := os.Getwd()
 	meqaPath := filepath.Join(wd, "../../../testdata")
-	swaggerPath := filepath.Join(meqaPath, "petstore_meqa.yml")
-	planPath := filepath.Join(meqaPath, "object.yml")
-	resultPath := filepath.Join(meqaPath, "result.yml")
-	testToRun := "all"
-	username := ""
-	password := ""
-	apitoken := ""
-	verbose := false
+	o := &runOptions{
+		meqaPath:     meqaPath,
+		swaggerFile:  filepath.Join(meqaPath, "petstore_meqa.yml"),
+		testPlanFile: filepath.Join(meqaPath, "object.yml"),
+		resultPath:   filepath.Join(meqaPath, "result.yml"),
+		testToRun:    "all",
+		cleanup:      mqplan.CleanupNone,
+		dbDump:       mqplan.DBDumpNever,
+		setFlags:     map[string]bool{},
+	}
 
 	mqutil.Logger = mqutil.NewFileLogger(filepath.Join(meqaPath, "mqgo.log"))
-	runMeqa(&meqaPath, &swaggerPath, &planPath, &resultPath, &testToRun, &username, &password, &apitoken, &verbose)
+	runMeqa(o)
+}
+
+func TestOverridePlan(t *testing.T) {
+	plan := &mqplan.TestPlan{Timeout: "5s", Retry: &mqplan.Retry{Count: 3, Backoff: "1s"}, RPS: 10, MaxInFlight: 2}
+
+	// The flags left out keep the plan's settings.
+	overridePlan(plan, &runOptions{setFlags: map[string]bool{}})
+	if plan.Timeout != "5s" || plan.Retry.Count != 3 || plan.RPS != 10 || plan.MaxInFlight != 2 {
+		t.Errorf("expected the plan's settings kept, got %+v", plan)
+	}
+
+	// The flags given override them, even with 0.
+	overridePlan(plan, &runOptions{setFlags: map[string]bool{"retries": true, "rps": true}})
+	if plan.Retry.Count != 0 || plan.Retry.Backoff != "1s" || plan.RPS != 0 || plan.MaxInFlight != 2 {
+		t.Errorf("expected -retries 0 and -rps 0 to turn off the plan's settings, got %+v %+v", plan, plan.Retry)
+	}
+
+	// The TLS flags given override the plan's meqa_init, field by field.
+	plan.TLS = &mqplan.TLS{CA: "/plan/ca.pem", ServerName: "api.internal"}
+	overridePlan(plan, &runOptions{caFile: "ca.pem", insecure: true, setFlags: map[string]bool{"ca": true, "insecure": true}})
+	if plan.TLS.CA != "ca.pem" || plan.TLS.ServerName != "api.internal" || !plan.TLS.Insecure {
+		t.Errorf("expected -ca and -insecure over the plan's server name, got %+v", plan.TLS)
+	}
+}
+
+const suitePlan = `
+one pet:
+  - name: create
+    path: /pet
+    method: post
+`
+
+const suiteTraffic = `{"log": {"entries": [
+  {"request": {"method": "POST", "url": "http://petstore.example.com/v2/pet"}, "_path": "/pet",
+   "response": {"status": 200, "headers": [{"name": "Content-Type", "value": "application/json"}],
+                "content": {"text": "{\"id\": 7, \"name\": \"doggie\"}"}}},
+  {"request": {"method": "DELETE", "url": "http://petstore.example.com/v2/pet/7"}, "_path": "/pet/{petId}",
+   "response": {"status": 200}}
+]}}`
+
+func TestRunSuiteCleanup(t *testing.T) {
+	dir := t.TempDir()
+	o := &runOptions{
+		meqaPath:     dir,
+		swaggerFile:  "../mqplan/testdata/petstore_meqa.yml",
+		testPlanFile: filepath.Join(dir, "plan.yml"),
+		resultPath:   filepath.Join(dir, "result.yml"),
+		testToRun:    "one pet",
+		harFile:      filepath.Join(dir, "out.har"),
+		replayFile:   filepath.Join(dir, "in.har"),
+		cleanup:      mqplan.CleanupSuite,
+		dbDump:       mqplan.DBDumpNever,
+		setFlags:     map[string]bool{},
+	}
+	if err := os.WriteFile(o.testPlanFile, []byte(suitePlan), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(o.replayFile, []byte(suiteTraffic), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := resty.DefaultClient.GetClient()
+	defer func(transport http.RoundTripper) { client.Transport = transport }(client.Transport)
+	mqutil.Logger = mqutil.NewFileLogger(filepath.Join(dir, "mqgo.log"))
+	mqplan.Current = mqplan.TestPlan{}
+
+	// With -t the suite isn't cleaned up on its own, the pet is deleted once at the end of the run.
+	runMeqa(o)
+	recorded, err := mqplan.LoadHarFromFile(o.harFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var methods []string
+	for _, e := range recorded.Log.Entries {
+		methods = append(methods, e.Request.Method)
+	}
+	if strings.Join(methods, " ") != "POST DELETE" || len(mqplan.Current.Cleaner.Failures) != 0 {
+		t.Errorf("expected the pet created deleted once, got %v and %d failures", methods, len(mqplan.Current.Cleaner.Failures))
+	}
 }
 
 func TestMain(m *testing.M) {
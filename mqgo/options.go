@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/gbatanov/meqa/mqplan"
+	"github.com/gbatanov/meqa/mqswag"
+)
+
+// runOptions are the settings of mqgo run, filled from its flags.
+type runOptions struct {
+	meqaPath     string
+	swaggerFile  string
+	testPlanFile string
+	resultPath   string
+	testToRun    string
+
+	username    string
+	password    string
+	apiToken    string
+	verbose     bool
+	showSecrets bool
+
+	harFile    string
+	replayFile string
+	filter     mqswag.OperationFilter
+	dbIn       string
+	dbOut      string
+	fixtures   mqswag.StringList
+	cleanup    string
+	dbDump     string
+
+	timeout     string
+	retries     int
+	backoff     string
+	rps         float64
+	maxInFlight int
+
+	caFile     string
+	certFile   string
+	keyFile    string
+	serverName string
+	insecure   bool
+
+	// The flags given on the command line, which override the plan even when set to 0.
+	setFlags map[string]bool
+}
+
+func (o *runOptions) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.meqaPath, "d", meqaDataDir, "the directory where meqa config, log and output files reside")
+	fs.StringVar(&o.swaggerFile, "s", "", "the meqa generated OpenAPI (Swagger) spec file path")
+	fs.StringVar(&o.testPlanFile, "p", "", "the test plan file name")
+	fs.StringVar(&o.resultPath, "r", "result.yml", "the test result file name (default result.yml in meqa_data dir)")
+	fs.StringVar(&o.testToRun, "t", "all", "the test to run")
+	fs.StringVar(&o.username, "u", "", "the username for basic HTTP authentication")
+	fs.StringVar(&o.password, "w", "", "the password for basic HTTP authentication")
+	fs.StringVar(&o.apiToken, "a", "", "the api token for bearer HTTP authentication")
+	fs.BoolVar(&o.verbose, "v", false, "turn on verbose mode")
+	fs.BoolVar(&o.showSecrets, "show-secrets", false, "show the credentials in the curl commands and recorded traffic")
+	fs.StringVar(&o.harFile, "har", "", "record all the HTTP exchanges to this HAR file")
+	fs.StringVar(&o.replayFile, "replay", "", "serve the responses from this recorded HAR file instead of calling the server")
+	fs.StringVar(&o.dbIn, "db-in", "", "load the objects saved by an earlier run from this json file before running")
+	fs.StringVar(&o.dbOut, "db-out", "", "save the objects known at the end of the run to this json file")
+	fs.Var(&o.fixtures, "fixtures", "comma separated yaml or json files with the existing objects, keyed by definition name")
+	fs.StringVar(&o.dbDump, "db-dump", mqplan.DBDumpNever, "dump the client DB after the tests into the dbdump directory under -d - "+
+		strings.Join(mqplan.DBDumpModes, ", "))
+	fs.StringVar(&o.cleanup, "cleanup", mqplan.CleanupNone, "delete the objects the tests create after every suite or the whole run - "+
+		strings.Join(mqplan.CleanupModes, ", "))
+	fs.StringVar(&o.timeout, "timeout", "", "the request timeout, e.g. 10s, overriding the plan's (default 1m, 0 for none)")
+	fs.IntVar(&o.retries, "retries", 0, "retry the requests that hit a network error, a 5xx or a 429 this many times, overriding the plan's. "+
+		"Only the idempotent methods are retried, unless the plan's retry sets nonIdempotent")
+	fs.StringVar(&o.backoff, "backoff", "", "the delay before the first retry, doubled on every retry (default 500ms)")
+	fs.Float64Var(&o.rps, "rps", 0, "the max requests per second sent to the server, overriding the plan's (default 0 for no limit)")
+	fs.IntVar(&o.maxInFlight, "max-in-flight", 0, "the max requests waiting for a response at a time, overriding the plan's (default 0 for no limit)")
+	fs.StringVar(&o.caFile, "ca", "", "the PEM bundle of the CAs to trust on top of the system's, to verify the server's certificate")
+	fs.StringVar(&o.certFile, "cert", "", "the PEM client certificate for the servers that require mutual TLS")
+	fs.StringVar(&o.keyFile, "key", "", "the PEM private key of the client certificate")
+	fs.StringVar(&o.serverName, "server-name", "", "the server name to verify the server's certificate against, instead of the host")
+	fs.BoolVar(&o.insecure, "insecure", false, "don't verify the server's certificate")
+	o.filter.RegisterFlags(fs)
+}
+
+// parse parses the flags and records the ones given on the command line.
+func (o *runOptions) parse(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	o.setFlags = make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { o.setFlags[f.Name] = true })
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// tlsConfig creates the TLS config the tests call the server with. The CA bundle is trusted on top of the
+// system's CAs. The client certificate is presented to the servers that require mutual TLS. The server's
+// certificate is only left unverified with insecure.
+func tlsConfig(caFile string, certFile string, keyFile string, serverName string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName, InsecureSkipVerify: insecure}
+
+	if len(caFile) > 0 {
+		caBytes, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read the CA bundle %s: %s", caFile, err.Error())
+		}
+		caPool, err := x509.SystemCertPool()
+		if err != nil || caPool == nil {
+			caPool = x509.NewCertPool()
+		}
+		if !caPool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("no PEM certificate found in the CA bundle %s", caFile)
+		}
+		config.RootCAs = caPool
+	}
+
+	if len(certFile) > 0 || len(keyFile) > 0 {
+		if len(certFile) == 0 || len(keyFile) == 0 {
+			return nil, fmt.Errorf("the client certificate needs both a certificate and a key, -cert and -key")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't load the client certificate %s with the key %s: %s", certFile, keyFile, err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self signed client certificate and its key to the directory.
+func writeClientCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "meqa client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600)
+	return certFile, keyFile
+}
+
+func get(config *tls.Config, url string) error {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	resp, err := client.Get(url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+func TestTLSConfig(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	certFile, keyFile := writeClientCert(t, dir)
+
+	// The test server's certificate is for example.com.
+	config, err := tlsConfig(caFile, certFile, keyFile, "example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = get(config, server.URL); err != nil {
+		t.Errorf("expected the CA and the client certificate to be accepted: %s", err.Error())
+	}
+
+	config, _ = tlsConfig("", certFile, keyFile, "", false)
+	if err = get(config, server.URL); err == nil {
+		t.Errorf("expected the server's certificate to be rejected without the CA")
+	}
+	config, _ = tlsConfig("", certFile, keyFile, "", true)
+	if err = get(config, server.URL); err != nil {
+		t.Errorf("expected the server's certificate to be skipped with insecure: %s", err.Error())
+	}
+	config, _ = tlsConfig(caFile, "", "", "example.com", false)
+	if err = get(config, server.URL); err == nil {
+		t.Errorf("expected the server to require the client certificate")
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _ := writeClientCert(t, dir)
+	if _, err := tlsConfig("", certFile, "", "", false); err == nil {
+		t.Errorf("expected an error for the certificate without the key")
+	}
+	if _, err := tlsConfig(filepath.Join(dir, "missing.pem"), "", "", "", false); err == nil {
+		t.Errorf("expected an error for the missing CA bundle")
+	}
+	notPem := filepath.Join(dir, "ca.txt")
+	os.WriteFile(notPem, []byte("not a certificate"), 0600)
+	if _, err := tlsConfig(notPem, "", "", "", false); err == nil {
+		t.Errorf("expected an error for the CA bundle without certificates")
+	}
+}
@@ -0,0 +1,212 @@
+// Package mqmock implements a mock of the REST service described by a swagger spec.
+package mqmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gbatanov/meqa/mqplan"
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+	"github.com/go-openapi/spec"
+)
+
+var pathParamRegexp = regexp.MustCompile(`\{([^/]*)\}`)
+
+// route maps the requests of one operation to the operation.
+type route struct {
+	method     string
+	path       string // The path template in the swagger spec.
+	matcher    *regexp.Regexp
+	paramNames []string // The names of the path params, in the order they appear in the path.
+	op         *spec.Operation
+	validators []*paramValidator
+	class      string // The class of the objects the operation works on, used by the stateful server.
+}
+
+func newRoute(pathName string, pathItem *spec.PathItem, method string, op *spec.Operation, swagger *mqswag.Swagger) (*route, error) {
+	rt := &route{method: method, path: pathName, op: op}
+	parts := pathParamRegexp.Split(pathName, -1)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	rt.matcher = regexp.MustCompile("^" + strings.Join(parts, "([^/]+)") + "/?$")
+	for _, m := range pathParamRegexp.FindAllStringSubmatch(pathName, -1) {
+		rt.paramNames = append(rt.paramNames, m[1])
+	}
+
+	params := mqplan.ParamsAdd(append([]spec.Parameter{}, op.Parameters...), pathItem.Parameters)
+	for _, p := range params {
+		v, err := newParamValidator(p, swagger)
+		if err != nil {
+			return nil, err
+		}
+		rt.validators = append(rt.validators, v)
+	}
+	return rt, nil
+}
+
+// match checks whether the path (without the base path) belongs to the route. It returns the path
+// param values.
+func (rt *route) match(path string) (map[string]string, bool) {
+	m := rt.matcher.FindStringSubmatch(path)
+	if m == nil {
+		return nil, false
+	}
+	values := make(map[string]string)
+	for i, name := range rt.paramNames {
+		values[name] = m[i+1]
+	}
+	return values, true
+}
+
+// The operation that the route implements, which is the method unless the tag says otherwise.
+func (rt *route) operation() string {
+	tag := mqswag.GetMeqaTag(rt.op.Description)
+	if tag != nil && len(tag.Operation) > 0 {
+		return tag.Operation
+	}
+	return rt.method
+}
+
+type byPathParams []*route
+
+func (r byPathParams) Len() int      { return len(r) }
+func (r byPathParams) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+
+// Literal paths go first, so that /pet/findByStatus is not taken as /pet/{petId}.
+func (r byPathParams) Less(i, j int) bool {
+	ni := len(r[i].paramNames)
+	nj := len(r[j].paramNames)
+	return ni < nj || (ni == nj && r[i].path < r[j].path) || (ni == nj && r[i].path == r[j].path && r[i].method < r[j].method)
+}
+
+// Server is a mock of the REST service. Every operation in the swagger spec validates the request
+// against the operation's parameters, then responds with the data generated from the response schema.
+// When the server is stateful, it behaves like a CRUD backend on top of the object DB, see serveFromDB.
+type Server struct {
+	Swagger  *mqswag.Swagger
+	Stateful bool
+
+	db       *mqswag.DB
+	routes   []*route
+	keyProps map[string]string // The class to the property that identifies its objects.
+	nextIds  map[string]int64
+	mutex    sync.Mutex
+}
+
+func NewServer(swagger *mqswag.Swagger, stateful bool) (*Server, error) {
+	s := &Server{Swagger: swagger, Stateful: stateful}
+	s.db = &mqswag.DB{}
+	s.db.Init(swagger)
+	for pathName, pathItem := range swagger.Paths.Paths {
+		item := pathItem // pathItem is reused in the loop
+		for _, method := range mqswag.MethodAll {
+			op := mqplan.GetOperationByMethod(&item, method)
+			if op == nil {
+				continue
+			}
+			rt, err := newRoute(pathName, &item, method, op, swagger)
+			if err != nil {
+				return nil, err
+			}
+			s.routes = append(s.routes, rt)
+		}
+	}
+	sort.Sort(byPathParams(s.routes))
+	if stateful {
+		s.classifyRoutes()
+	}
+	return s, nil
+}
+
+// DB returns the objects the mock server holds.
+func (s *Server) DB() *mqswag.DB {
+	return s.db
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	if body == nil {
+		w.WriteHeader(status)
+		return
+	}
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(bodyBytes)
+}
+
+func errorBody(status int, msg string) map[string]interface{} {
+	return map[string]interface{}{"code": status, "message": msg}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	bodyBytes, _ := json.Marshal(errorBody(status, msg))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(bodyBytes)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if base := strings.TrimSuffix(s.Swagger.BasePath, "/"); len(base) > 0 {
+		if !strings.HasPrefix(path, base) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("path not found: %s", r.URL.Path))
+			return
+		}
+		path = path[len(base):]
+	}
+	method := strings.ToLower(r.Method)
+	pathFound := false
+	for _, rt := range s.routes {
+		values, ok := rt.match(path)
+		if !ok {
+			continue
+		}
+		pathFound = true
+		if rt.method == method {
+			s.serve(rt, values, w, r)
+			return
+		}
+	}
+	if pathFound {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed on %s", r.Method, r.URL.Path))
+		return
+	}
+	writeError(w, http.StatusNotFound, fmt.Sprintf("path not found: %s", r.URL.Path))
+}
+
+func (s *Server) serve(rt *route, pathValues map[string]string, w http.ResponseWriter, r *http.Request) {
+	req, err := validateRequest(rt.validators, r, pathValues)
+	if err != nil {
+		mqutil.Logger.Printf("%s %s -> %d %s", r.Method, r.URL.String(), http.StatusBadRequest, err.Error())
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	status, respSpec := mqswag.SuccessResponse(rt.op)
+	var body interface{}
+	handled := false
+	if s.Stateful {
+		status, body, handled = s.serveFromDB(rt, req, status, respSpec)
+	}
+	if !handled && respSpec != nil && respSpec.Schema != nil {
+		body, err = mqplan.GenerateValue(respSpec.Schema, s.db)
+		if err != nil {
+			mqutil.Logger.Printf("%s %s -> %d %s", r.Method, r.URL.String(), http.StatusInternalServerError, err.Error())
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	mqutil.Logger.Printf("%s %s -> %d", r.Method, r.URL.String(), status)
+	writeJson(w, status, body)
+}
@@ -0,0 +1,162 @@
+package mqmock
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqplan"
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func newTestServer(t *testing.T, stateful bool) *httptest.Server {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("../mqplan/testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(swagger, stateful)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func call(t *testing.T, ts *httptest.Server, method string, path string, body string) (int, interface{}) {
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestMockRouting(t *testing.T) {
+	ts := newTestServer(t, false)
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"GET", "/v2/pet/12", "", http.StatusOK},
+		{"GET", "/v2/pet/abc", "", http.StatusBadRequest},
+		{"GET", "/v2/pet/findByStatus?status=available", "", http.StatusOK},
+		{"GET", "/v2/pet/findByStatus?status=gone", "", http.StatusBadRequest},
+		{"POST", "/v2/pet", "", http.StatusBadRequest},
+		{"POST", "/v2/pet", `{"name": "doggie", "photoUrls": ["a"]}`, http.StatusOK},
+		{"PATCH", "/v2/pet/12", "", http.StatusMethodNotAllowed},
+		{"GET", "/v2/nothing", "", http.StatusNotFound},
+		{"GET", "/v1/pet/12", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		status, _ := call(t, ts, c.method, c.path, c.body)
+		if status != c.status {
+			t.Errorf("%s %s: expected status %d, got %d", c.method, c.path, c.status, status)
+		}
+	}
+}
+
+func TestMockStateful(t *testing.T) {
+	ts := newTestServer(t, true)
+	status, created := call(t, ts, "POST", "/v2/pet", `{"id": 7, "name": "doggie", "photoUrls": [], "status": "sold"}`)
+	if status != http.StatusOK {
+		t.Fatalf("post failed with status %d", status)
+	}
+	if created.(map[string]interface{})["name"] != "doggie" {
+		t.Errorf("the post should echo the pet, got %v", created)
+	}
+	_, pet := call(t, ts, "GET", "/v2/pet/7", "")
+	if m, ok := pet.(map[string]interface{}); !ok || m["name"] != "doggie" {
+		t.Errorf("expected the posted pet, got %v", pet)
+	}
+	_, pets := call(t, ts, "GET", "/v2/pet/findByStatus?status=sold", "")
+	if list, ok := pets.([]interface{}); !ok || len(list) != 1 {
+		t.Errorf("expected one sold pet, got %v", pets)
+	}
+}
+
+func TestMockCRUD(t *testing.T) {
+	ts := newTestServer(t, true)
+	status, created := call(t, ts, "POST", "/v2/pet", `{"name": "doggie", "photoUrls": []}`)
+	if status != http.StatusOK {
+		t.Fatalf("post failed with status %d", status)
+	}
+	if created.(map[string]interface{})["id"] == nil {
+		t.Fatalf("the mock should assign an id, got %v", created)
+	}
+	if status, _ := call(t, ts, "PUT", "/v2/pet", `{"id": 1, "name": "kitty", "photoUrls": []}`); status != http.StatusOK {
+		t.Errorf("put failed with status %d", status)
+	}
+	if _, pet := call(t, ts, "GET", "/v2/pet/1", ""); pet.(map[string]interface{})["name"] != "kitty" {
+		t.Errorf("expected the updated pet, got %v", pet)
+	}
+	if status, _ := call(t, ts, "PUT", "/v2/pet", `{"id": 2, "name": "tiger", "photoUrls": []}`); status != http.StatusOK {
+		t.Errorf("put failed with status %d", status)
+	}
+	if _, pet := call(t, ts, "GET", "/v2/pet/2", ""); pet.(map[string]interface{})["name"] != "tiger" {
+		t.Errorf("a put of a new pet should create it, got %v", pet)
+	}
+	if status, _ := call(t, ts, "DELETE", "/v2/pet/1", ""); status != http.StatusOK {
+		t.Errorf("delete failed with status %d", status)
+	}
+	if status, _ := call(t, ts, "GET", "/v2/pet/1", ""); status != http.StatusNotFound {
+		t.Errorf("the deleted pet should be gone, got %d", status)
+	}
+	if status, _ := call(t, ts, "DELETE", "/v2/pet/1", ""); status != http.StatusNotFound {
+		t.Errorf("deleting the pet twice should fail with 404, got %d", status)
+	}
+}
+
+// The object test plan generated from the spec should pass against the stateful mock.
+func TestMockRunsObjectPlan(t *testing.T) {
+	ts := newTestServer(t, true)
+	dir := t.TempDir()
+	swagger, err := mqswag.CreateSwaggerFromURL("../mqplan/testdata/petstore_meqa.yml", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swagger.Host = strings.TrimPrefix(ts.URL, "http://")
+	dag := mqswag.NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	dag.Sort()
+	generated, err := mqplan.GenerateTestPlan(swagger, dag, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	planFile := filepath.Join(dir, "object.yml")
+	if err = generated.DumpToFile(planFile); err != nil {
+		t.Fatal(err)
+	}
+
+	db := &mqswag.DB{}
+	db.Init(swagger)
+	plan := &mqplan.TestPlan{}
+	if err = plan.InitFromFile(planFile, db); err != nil {
+		t.Fatal(err)
+	}
+	for _, suite := range plan.SuiteList {
+		counts, err := plan.Run(suite.Name, nil)
+		if err != nil || counts[mqutil.Failed] > 0 {
+			t.Errorf("suite %s failed: %v", suite.Name, err)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package mqmock
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+	"github.com/go-openapi/spec"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const defaultKeyProp = "id"
+
+// bodyClass returns the class of the object in the request body.
+func (s *Server) bodyClass(rt *route) string {
+	for _, v := range rt.validators {
+		if v.param.In == "body" {
+			class, _ := s.Swagger.SchemaClass(v.param.Schema, v.param.Description)
+			return class
+		}
+	}
+	return ""
+}
+
+// responseClass returns the class of the success response, and whether it's an array of them.
+func (s *Server) responseClass(rt *route) (string, bool) {
+	_, respSpec := mqswag.SuccessResponse(rt.op)
+	if respSpec == nil {
+		return "", false
+	}
+	return s.Swagger.SchemaClass(respSpec.Schema, respSpec.Description)
+}
+
+// dagClass finds the class of the operation in the DAG. The objects a post creates are its children,
+// the objects other operations use are their parents.
+func dagClass(dag *mqswag.DAG, rt *route) string {
+	node := dag.NameMap[mqswag.GetDAGName(mqswag.TypeOp, rt.path, rt.method)]
+	if node == nil {
+		return ""
+	}
+	var classes []string
+	if rt.operation() == mqswag.MethodPost {
+		for _, c := range node.Children {
+			if c.GetType() == mqswag.TypeDef {
+				classes = append(classes, c.GetName())
+			}
+		}
+	} else {
+		for _, n := range dag.NameMap {
+			if n.GetType() != mqswag.TypeDef {
+				continue
+			}
+			for _, c := range n.Children {
+				if c == node {
+					classes = append(classes, n.GetName())
+				}
+			}
+		}
+	}
+	// Only trust the DAG when the operation is about a single class.
+	if len(classes) == 1 {
+		return classes[0]
+	}
+	return ""
+}
+
+// routeClass figures out the class the operation works on. The tags are the most reliable, then the
+// schemas of the body and the response, then the DAG.
+func (s *Server) routeClass(dag *mqswag.DAG, rt *route) string {
+	if tag := mqswag.GetMeqaTag(rt.op.Description); tag != nil && len(tag.Class) > 0 {
+		return tag.Class
+	}
+	for i := len(rt.validators) - 1; i >= 0; i-- {
+		v := rt.validators[i]
+		if tag := mqswag.GetMeqaTag(v.param.Description); v.param.In == "path" && tag != nil && len(tag.Class) > 0 {
+			return tag.Class
+		}
+	}
+	if class := s.bodyClass(rt); len(class) > 0 {
+		return class
+	}
+	if class, _ := s.responseClass(rt); len(class) > 0 {
+		return class
+	}
+	if dag != nil {
+		return dagClass(dag, rt)
+	}
+	return ""
+}
+
+// classifyRoutes finds the class of every route, and the property that identifies the objects of
+// each class.
+func (s *Server) classifyRoutes() {
+	dag := mqswag.NewDAG()
+	err := s.Swagger.AddToDAG(dag)
+	if err != nil {
+		mqutil.Logger.Printf("mock server can't build the DAG, only the tags will be used: %s", err.Error())
+		dag = nil
+	}
+
+	s.keyProps = make(map[string]string)
+	s.nextIds = make(map[string]int64)
+	for _, rt := range s.routes {
+		rt.class = s.routeClass(dag, rt)
+		for _, v := range rt.validators {
+			tag := mqswag.GetMeqaTag(v.param.Description)
+			if v.param.In == "path" && tag != nil && len(tag.Class) > 0 && len(tag.Property) > 0 {
+				s.keyProps[tag.Class] = tag.Property
+			}
+		}
+	}
+	for _, rt := range s.routes {
+		if len(rt.class) == 0 || len(s.keyProps[rt.class]) > 0 {
+			continue
+		}
+		if schema := s.db.GetSchema(rt.class); schema != nil {
+			if _, ok := schema.GetProperties(s.Swagger)[defaultKeyProp]; ok {
+				s.keyProps[rt.class] = defaultKeyProp
+			}
+		}
+	}
+}
+
+// lookupCriteria collects the request parameters that identify the objects of the route's class. These
+// are the parameters tagged as the class's properties. When there is none, the last path param is taken
+// as the key of the object.
+func (s *Server) lookupCriteria(rt *route, req *Request) map[string]interface{} {
+	var criteria map[string]interface{}
+	for _, v := range rt.validators {
+		tag := mqswag.GetMeqaTag(v.param.Description)
+		if tag == nil || tag.Class != rt.class || len(tag.Property) == 0 || v.param.In == "body" {
+			continue
+		}
+		if value, ok := req.section(v.param.In)[v.param.Name]; ok {
+			if criteria == nil {
+				criteria = make(map[string]interface{})
+			}
+			criteria[tag.Property] = value
+		}
+	}
+	key := s.keyProps[rt.class]
+	if criteria == nil && len(key) > 0 && len(rt.paramNames) > 0 {
+		if value, ok := req.PathParams[rt.paramNames[len(rt.paramNames)-1]]; ok {
+			criteria = map[string]interface{}{key: value}
+		}
+	}
+	return criteria
+}
+
+// assignKey gives the new object a key if it doesn't have one, like a backend assigning ids.
+func (s *Server) assignKey(class string, obj map[string]interface{}) {
+	key := s.keyProps[class]
+	if len(key) == 0 || obj[key] != nil {
+		return
+	}
+	isString := false
+	if schema := s.db.GetSchema(class); schema != nil {
+		prop := schema.GetProperties(s.Swagger)[key]
+		isString = prop.Type.Contains(gojsonschema.TYPE_STRING)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for {
+		s.nextIds[class]++
+		var id interface{} = s.nextIds[class]
+		if isString {
+			id = strconv.FormatInt(s.nextIds[class], 10)
+		}
+		if len(s.db.Find(class, map[string]interface{}{key: id}, nil, mqutil.InterfaceEquals, 1)) == 0 {
+			obj[key] = id
+			return
+		}
+	}
+}
+
+func notFound(class string, criteria map[string]interface{}) (int, interface{}, bool) {
+	return http.StatusNotFound, errorBody(http.StatusNotFound, fmt.Sprintf("%s not found: %v", class, criteria)), true
+}
+
+// serveFromDB handles the request like a CRUD backend. Posts insert the object in the body, gets look
+// up the objects by the tagged parameters, puts and patches update the object and deletes remove it.
+// A put with the key only in the body creates the object if it doesn't exist.
+// The operations on objects that don't exist fail with 404. It returns false if the request can't be
+// handled this way, in which case a generated response is used.
+func (s *Server) serveFromDB(rt *route, req *Request, status int, respSpec *spec.Response) (int, interface{}, bool) {
+	if len(rt.class) == 0 {
+		return status, nil, false
+	}
+	var respClass string
+	var respIsArray bool
+	if respSpec != nil {
+		respClass, respIsArray = s.Swagger.SchemaClass(respSpec.Schema, respSpec.Description)
+	}
+	// The object is returned when the response is about it. Otherwise it's up to the generator.
+	respond := func(obj interface{}) (int, interface{}, bool) {
+		if respSpec != nil && respSpec.Schema != nil && (respClass != rt.class || respIsArray) {
+			return status, nil, false
+		}
+		if respSpec == nil || respSpec.Schema == nil {
+			obj = nil
+		}
+		return status, obj, true
+	}
+	criteria := s.lookupCriteria(rt, req)
+	body, _ := req.Body.(map[string]interface{})
+
+	switch rt.operation() {
+	case mqswag.MethodPost:
+		if body == nil || s.bodyClass(rt) != rt.class {
+			return status, nil, false
+		}
+		s.assignKey(rt.class, body)
+		s.db.Insert(rt.class, body, nil)
+		return respond(body)
+	case mqswag.MethodGet, mqswag.MethodHead:
+		found := s.db.Find(rt.class, criteria, nil, mqutil.InterfaceEquals, -1)
+		if respIsArray && respClass == rt.class {
+			return status, append([]interface{}{}, found...), true
+		}
+		if len(found) == 0 {
+			if criteria != nil {
+				return notFound(rt.class, criteria)
+			}
+			return status, nil, false
+		}
+		return respond(found[0])
+	case mqswag.MethodPut, mqswag.MethodPatch:
+		if body == nil {
+			return status, nil, false
+		}
+		key := s.keyProps[rt.class]
+		// A put of the whole object that isn't addressed by the path creates or replaces it.
+		upsert := false
+		if criteria == nil && len(key) > 0 && body[key] != nil {
+			criteria = map[string]interface{}{key: body[key]}
+			upsert = rt.method == mqswag.MethodPut
+		}
+		if criteria == nil {
+			return status, nil, false
+		}
+		// The object keeps its key even if the body doesn't repeat it.
+		for k, v := range criteria {
+			if body[k] == nil {
+				body[k] = v
+			}
+		}
+		patch := rt.method == mqswag.MethodPatch
+		if s.db.Update(rt.class, criteria, nil, mqutil.InterfaceEquals, body, 1, patch) == 0 {
+			if !upsert {
+				return notFound(rt.class, criteria)
+			}
+			s.db.Insert(rt.class, body, nil)
+		}
+		found := s.db.Find(rt.class, criteria, nil, mqutil.InterfaceEquals, 1)
+		if len(found) == 0 {
+			// The update changed the key.
+			return respond(body)
+		}
+		return respond(found[0])
+	case mqswag.MethodDelete:
+		if criteria == nil {
+			return status, nil, false
+		}
+		if s.db.Delete(rt.class, criteria, nil, mqutil.InterfaceEquals, 1) == 0 {
+			return notFound(rt.class, criteria)
+		}
+		return status, nil, respSpec == nil || respSpec.Schema == nil
+	}
+	return status, nil, false
+}
@@ -0,0 +1,261 @@
+package mqmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+	"github.com/go-openapi/spec"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// compileSchema compiles the swagger schema into a json schema validator. The swagger definitions
+// are added to the schema document so that the $refs can be resolved.
+func compileSchema(schema *spec.Schema, swagger *mqswag.Swagger) (*gojsonschema.Schema, error) {
+	schemaBytes, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	err = json.Unmarshal(schemaBytes, &doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(swagger.Definitions) > 0 {
+		doc["definitions"] = swagger.Definitions
+	}
+	return gojsonschema.NewSchema(gojsonschema.NewGoLoader(doc))
+}
+
+// paramValidator validates one operation parameter.
+type paramValidator struct {
+	param  spec.Parameter
+	schema *gojsonschema.Schema
+}
+
+func newParamValidator(param spec.Parameter, swagger *mqswag.Swagger) (*paramValidator, error) {
+	v := &paramValidator{param: param}
+	var schema *spec.Schema
+	if param.In == "body" {
+		schema = param.Schema
+	} else if param.Type != "file" && len(param.Type) > 0 {
+		schema = (*spec.Schema)(mqswag.CreateSchemaFromSimple(&param.SimpleSchema, &param.CommonValidations))
+	}
+	if schema != nil {
+		s, err := compileSchema(schema, swagger)
+		if err != nil {
+			return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't compile the schema of parameter %s: %s", param.Name, err.Error()))
+		}
+		v.schema = s
+	}
+	return v, nil
+}
+
+// convertSimple converts the string value of a non-body parameter to the type in the spec.
+func convertSimple(value string, s *spec.SimpleSchema) (interface{}, error) {
+	switch s.Type {
+	case gojsonschema.TYPE_INTEGER:
+		return strconv.ParseInt(value, 10, 64)
+	case gojsonschema.TYPE_NUMBER:
+		return strconv.ParseFloat(value, 64)
+	case gojsonschema.TYPE_BOOLEAN:
+		return strconv.ParseBool(value)
+	case gojsonschema.TYPE_ARRAY:
+		sep := ","
+		switch s.CollectionFormat {
+		case "ssv":
+			sep = " "
+		case "tsv":
+			sep = "\t"
+		case "pipes":
+			sep = "|"
+		}
+		var ar []interface{}
+		if len(value) == 0 {
+			return ar, nil
+		}
+		for _, v := range strings.Split(value, sep) {
+			var item interface{} = v
+			if s.Items != nil {
+				converted, err := convertSimple(v, &s.Items.SimpleSchema)
+				if err != nil {
+					return nil, err
+				}
+				item = converted
+			}
+			ar = append(ar, item)
+		}
+		return ar, nil
+	}
+	return value, nil
+}
+
+// removeNulls removes the null fields from the maps. Clients commonly send null for the fields that
+// are absent.
+func removeNulls(obj interface{}) interface{} {
+	if m, ok := obj.(map[string]interface{}); ok {
+		for k, v := range m {
+			if v == nil {
+				delete(m, k)
+			} else {
+				m[k] = removeNulls(v)
+			}
+		}
+	} else if a, ok := obj.([]interface{}); ok {
+		for i, v := range a {
+			a[i] = removeNulls(v)
+		}
+	}
+	return obj
+}
+
+func decodeJson(r io.Reader) (interface{}, error) {
+	var obj interface{}
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	err := d.Decode(&obj)
+	if err == io.EOF {
+		return nil, nil
+	}
+	return obj, err
+}
+
+// Request holds the validated parameters of a request, converted to the types in the spec.
+type Request struct {
+	PathParams   map[string]interface{}
+	QueryParams  map[string]interface{}
+	HeaderParams map[string]interface{}
+	FormParams   map[string]interface{}
+	Body         interface{}
+}
+
+func (req *Request) section(in string) map[string]interface{} {
+	switch in {
+	case "path":
+		return req.PathParams
+	case "query":
+		return req.QueryParams
+	case "header":
+		return req.HeaderParams
+	case "formData":
+		return req.FormParams
+	}
+	return nil
+}
+
+// raw returns the string value of the non-body parameter, and whether it is present.
+func (v *paramValidator) raw(r *http.Request, pathValues map[string]string) (string, bool) {
+	name := v.param.Name
+	switch v.param.In {
+	case "path":
+		value, ok := pathValues[name]
+		return value, ok
+	case "query":
+		values, ok := r.URL.Query()[name]
+		if !ok || len(values) == 0 {
+			return "", false
+		}
+		return strings.Join(values, ","), true
+	case "header":
+		values, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !ok || len(values) == 0 {
+			return "", false
+		}
+		return values[0], true
+	case "formData":
+		if v.param.Type == "file" {
+			if r.MultipartForm != nil && len(r.MultipartForm.File[name]) > 0 {
+				return r.MultipartForm.File[name][0].Filename, true
+			}
+			return "", false
+		}
+		values, ok := r.Form[name]
+		if !ok || len(values) == 0 {
+			return "", false
+		}
+		return values[0], true
+	}
+	return "", false
+}
+
+func (v *paramValidator) validate(value interface{}) error {
+	if v.schema == nil {
+		return nil
+	}
+	result, err := v.schema.Validate(gojsonschema.NewGoLoader(value))
+	if err != nil {
+		return err
+	}
+	if !result.Valid() {
+		var msgs []string
+		for _, e := range result.Errors() {
+			msgs = append(msgs, e.String())
+		}
+		return fmt.Errorf("parameter %s (in %s) is invalid: %s", v.param.Name, v.param.In, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// validateRequest checks the request against the operation's parameters. It returns the parameters
+// found in the request.
+func validateRequest(validators []*paramValidator, r *http.Request, pathValues map[string]string) (*Request, error) {
+	req := &Request{
+		PathParams:   make(map[string]interface{}),
+		QueryParams:  make(map[string]interface{}),
+		HeaderParams: make(map[string]interface{}),
+		FormParams:   make(map[string]interface{}),
+	}
+	for _, v := range validators {
+		if v.param.In == "formData" && r.Form == nil {
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+				r.ParseMultipartForm(32 << 20)
+			} else {
+				r.ParseForm()
+			}
+		}
+		if v.param.In == "body" {
+			body, err := decodeJson(r.Body)
+			if err != nil {
+				return nil, fmt.Errorf("request body is not valid json: %s", err.Error())
+			}
+			body = removeNulls(body)
+			if body == nil {
+				if v.param.Required {
+					return nil, fmt.Errorf("request body is required")
+				}
+				continue
+			}
+			if err = v.validate(body); err != nil {
+				return nil, err
+			}
+			req.Body = body
+			continue
+		}
+
+		str, present := v.raw(r, pathValues)
+		if !present {
+			if v.param.Required {
+				return nil, fmt.Errorf("required parameter %s (in %s) is missing", v.param.Name, v.param.In)
+			}
+			continue
+		}
+		var value interface{} = str
+		if v.param.Type != "file" {
+			converted, err := convertSimple(str, &v.param.SimpleSchema)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %s (in %s) is not a valid %s: %s", v.param.Name, v.param.In, v.param.Type, str)
+			}
+			if err = v.validate(converted); err != nil {
+				return nil, err
+			}
+			value = converted
+		}
+		req.section(v.param.In)[v.param.Name] = value
+	}
+	return req, nil
+}
@@ -0,0 +1,193 @@
+package mqplan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+	"github.com/go-openapi/spec"
+)
+
+const (
+	CleanupNone  = "none"  // Leave the objects created behind.
+	CleanupSuite = "suite" // Delete the objects created after every test suite.
+	CleanupRun   = "run"   // Delete the objects created at the end of the run.
+)
+
+var CleanupModes = []string{CleanupNone, CleanupSuite, CleanupRun}
+
+// CleanupFailure is an object created during the run that couldn't be deleted.
+type CleanupFailure struct {
+	Class  string
+	Object map[string]interface{}
+	Reason string
+}
+
+func (f *CleanupFailure) ToString() string {
+	objBytes, _ := mqutil.MarshalJsonIndentNoEscape(f.Object)
+	return fmt.Sprintf("%s %s: %s", f.Class, strings.TrimSpace(string(objBytes)), f.Reason)
+}
+
+// Cleaner keeps track of the objects the tests create, and deletes them when the suite or the run is over.
+type Cleaner struct {
+	PerSuite bool
+	Failures []*CleanupFailure // The objects that couldn't be deleted.
+
+	dag     *mqswag.DAG
+	created *mqswag.DB // The objects created and not deleted yet.
+	testId  int
+}
+
+// NewCleaner creates the cleaner for the mode, nil for CleanupNone. The dag tells the delete operations
+// of the objects and the order to delete them in.
+func NewCleaner(mode string, swagger *mqswag.Swagger, dag *mqswag.DAG) (*Cleaner, error) {
+	switch mode {
+	case CleanupNone, "":
+		return nil, nil
+	case CleanupSuite, CleanupRun:
+	default:
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("unknown cleanup mode: %s", mode))
+	}
+	c := &Cleaner{PerSuite: mode == CleanupSuite, dag: dag, created: &mqswag.DB{}}
+	c.created.Init(swagger)
+	return c, nil
+}
+
+// pathParamValue finds the value the path param of the delete operation takes for the object.
+func pathParamValue(p spec.Parameter, class string, obj map[string]interface{}) (interface{}, bool) {
+	if tag := mqswag.GetMeqaTag(p.Description); tag != nil && tag.Class == class && len(tag.Property) > 0 {
+		v, ok := obj[tag.Property]
+		return v, ok
+	}
+	if v, ok := obj[p.Name]; ok {
+		return v, true
+	}
+	// e.g. petId for the id of a Pet.
+	if v, ok := obj["id"]; ok && (strings.EqualFold(p.Name, class+"id") || strings.EqualFold(p.Name, "id")) {
+		return v, true
+	}
+	return nil, false
+}
+
+// deleteTest creates the test that deletes the object, using the first delete operation whose path
+// params can all be taken from the object. Returns nil if there is none.
+func (c *Cleaner) deleteTest(swagger *mqswag.Swagger, class string, obj map[string]interface{}) *Test {
+	defNode := c.dag.NameMap[mqswag.GetDAGName(mqswag.TypeDef, class, "")]
+	if defNode == nil {
+		return nil
+	}
+	for _, child := range defNode.Children {
+		if child.GetType() != mqswag.TypeOp || !OperationMatches(child, mqswag.MethodDelete) {
+			continue
+		}
+		op := child.Data.(*spec.Operation)
+		params := op.Parameters
+		if pathItem, ok := swagger.Paths.Paths[child.GetName()]; ok {
+			params = ParamsAdd(append([]spec.Parameter{}, op.Parameters...), pathItem.Parameters)
+		}
+		pathParams := make(map[string]interface{})
+		resolved := true
+		for _, p := range params {
+			if p.In != "path" {
+				continue
+			}
+			if v, ok := pathParamValue(p, class, obj); ok {
+				pathParams[p.Name] = v
+			} else {
+				resolved = false
+			}
+		}
+		if !resolved || len(pathParams) == 0 {
+			continue
+		}
+		c.testId++
+		t := CreateTestFromOp(child, c.testId)
+		t.Name = "cleanup_" + t.Name
+		t.PathParams = pathParams
+		return t
+	}
+	return nil
+}
+
+// CleanUp deletes the objects created by the tests since the last clean up, the objects depending on the
+// others first. The objects that can't be deleted are added to the cleaner's failures.
+func (plan *TestPlan) CleanUp() {
+	c := plan.Cleaner
+	if c == nil {
+		return
+	}
+	suite := CreateTestSuite("cleanup", nil, plan)
+	suite.db = plan.db.CloneSchema()
+
+	order := c.dag.TopologicalOrder()
+	for i := len(order) - 1; i >= 0; i-- {
+		if order[i].GetType() != mqswag.TypeDef {
+			continue
+		}
+		class := order[i].GetName()
+		objects := c.created.Find(class, nil, nil, mqswag.MatchAlways, -1)
+		for j := len(objects) - 1; j >= 0; j-- {
+			obj := objects[j].(map[string]interface{})
+			// Forget the object whatever happens, it's only tried once.
+			c.created.Delete(class, obj, nil, mqutil.InterfaceEquals, 1)
+
+			t := c.deleteTest(plan.swagger, class, obj)
+			if t == nil {
+				c.Failures = append(c.Failures, &CleanupFailure{class, obj, "no delete operation takes the object"})
+				continue
+			}
+			t.suite = suite
+			dup := t.Duplicate()
+			if err := dup.Run(suite); err != nil {
+				reason := fmt.Sprintf("%s %s failed", dup.Method, dup.Path)
+				if dup.resp != nil {
+					reason = fmt.Sprintf("%s %s returned %d", dup.Method, dup.Path, dup.resp.StatusCode())
+				}
+				mqutil.Logger.Printf("cleanup of %s failed: %s", class, err.Error())
+				c.Failures = append(c.Failures, &CleanupFailure{class, obj, reason})
+			}
+		}
+	}
+}
+
+// recordCreated keeps track of the object created by the test, for the clean up.
+func (t *Test) recordCreated(method string, className string, comp *Comparison, associations map[string]map[string]interface{}) {
+	if t.suite == nil || t.suite.plan == nil || t.suite.plan.Cleaner == nil {
+		return
+	}
+	created := t.suite.plan.Cleaner.created
+	switch method {
+	case mqswag.MethodDelete:
+		created.Delete(className, comp.oldUsed, associations, mqutil.InterfaceEquals, -1)
+	case mqswag.MethodPost:
+		created.Insert(className, comp.new, associations)
+	case mqswag.MethodPatch, mqswag.MethodPut:
+		count := created.Update(className, comp.oldUsed, associations, mqutil.InterfaceEquals, comp.new, 1, method == mqswag.MethodPatch)
+		if count == 0 && method == mqswag.MethodPut {
+			// A PUT of an object not created before creates it, e.g. the upsert by id.
+			created.Insert(className, comp.new, associations)
+		}
+	}
+}
+
+// PrintCleanupReport prints the objects that couldn't be cleaned up.
+func (plan *TestPlan) PrintCleanupReport() {
+	c := plan.Cleaner
+	if c == nil {
+		return
+	}
+	if len(c.Failures) == 0 {
+		fmt.Print(mqutil.GREEN)
+		fmt.Println("All the objects created were cleaned up.")
+		fmt.Print(mqutil.END)
+		return
+	}
+	fmt.Print(mqutil.RED)
+	fmt.Printf("Objects not cleaned up: %d\n", len(c.Failures))
+	fmt.Print(mqutil.END)
+	for _, f := range c.Failures {
+		fmt.Printf("\t%s\n", f.ToString())
+		mqutil.Logger.Printf("not cleaned up: %s", f.ToString())
+	}
+}
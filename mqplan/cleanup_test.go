@@ -0,0 +1,131 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+const cleanupPlan = `
+two pets:
+  - name: first
+    path: /pet
+    method: post
+  - name: second
+    path: /pet
+    method: post
+`
+
+func TestCleanUp(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := mqswag.NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	dag.Sort()
+	db := &mqswag.DB{}
+	db.Init(swagger)
+
+	// The pets are deleted last created first. The first one is already gone.
+	har := &Har{}
+	har.Log.Entries = []*HarEntry{
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie"}`),
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 8, "name": "kitty"}`),
+		harEntry("DELETE", "http://petstore.example.com/v2/pet/8", "/pet/{petId}", 200, ``),
+		harEntry("DELETE", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 404, `{"message": "not found"}`),
+	}
+	defer useTransport(NewReplayer(har))()
+
+	plan := &TestPlan{}
+	plan.Init(swagger, db)
+	// As with -db-out, the suites share the global DB.
+	plan.SharedDB = true
+	if plan.Cleaner, err = NewCleaner(CleanupRun, swagger, dag); err != nil {
+		t.Fatal(err)
+	}
+	if err = plan.AddFromString(cleanupPlan); err != nil {
+		t.Fatal(err)
+	}
+	if counts, err := plan.Run("two pets", nil); err != nil || counts[mqutil.Passed] != 2 {
+		t.Fatalf("expected both pets created, got %v %v", counts, err)
+	}
+	plan.CleanUp()
+
+	failures := plan.Cleaner.Failures
+	if len(failures) != 1 || failures[0].Object["id"] != json.Number("7") || !strings.Contains(failures[0].Reason, "404") {
+		t.Fatalf("expected the first pet reported, got %d failures", len(failures))
+	}
+	if remaining := db.Find("Pet", nil, nil, mqswag.MatchAlways, -1); len(remaining) != 1 {
+		t.Errorf("expected the pet deleted removed from the DB, %d left", len(remaining))
+	}
+	// Nothing is tried twice.
+	plan.CleanUp()
+	if len(plan.Cleaner.Failures) != 1 {
+		t.Errorf("expected no more failures, got %d", len(plan.Cleaner.Failures))
+	}
+
+	if _, err = NewCleaner("later", swagger, dag); err == nil {
+		t.Errorf("expected an unknown mode rejected")
+	}
+}
+
+const upsertPlan = `
+upsert:
+  - name: put
+    path: /pet
+    method: put
+    parameters:
+      body:
+        id: 9
+        name: rex
+`
+
+func TestCleanUpUpsert(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := mqswag.NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	dag.Sort()
+	db := &mqswag.DB{}
+	db.Init(swagger)
+
+	// The PUT creates the pet no test created before, so it's deleted too.
+	har := &Har{}
+	har.Log.Entries = []*HarEntry{
+		harEntry("PUT", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 9, "name": "rex"}`),
+		harEntry("DELETE", "http://petstore.example.com/v2/pet/9", "/pet/{petId}", 200, ``),
+	}
+	defer useTransport(NewReplayer(har))()
+
+	plan := &TestPlan{}
+	plan.Init(swagger, db)
+	plan.Recorder = NewHarRecorder()
+	if plan.Cleaner, err = NewCleaner(CleanupRun, swagger, dag); err != nil {
+		t.Fatal(err)
+	}
+	if err = plan.AddFromString(upsertPlan); err != nil {
+		t.Fatal(err)
+	}
+	if counts, err := plan.Run("upsert", nil); err != nil || counts[mqutil.Passed] != 1 {
+		t.Fatalf("expected the pet put, got %v %v", counts, err)
+	}
+	plan.CleanUp()
+
+	entries := plan.Recorder.har.Log.Entries
+	if len(entries) != 2 || entries[1].Request.Method != "DELETE" || len(plan.Cleaner.Failures) != 0 {
+		t.Errorf("expected the pet put deleted, got %d exchanges and %d failures", len(entries), len(plan.Cleaner.Failures))
+	}
+}
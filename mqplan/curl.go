@@ -0,0 +1,185 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+const maskedValue = "****"
+
+// The header names (in lower case) or fragments of names whose values are treated as secrets.
+var sensitiveHeaders = []string{"authorization", "cookie", "token", "secret", "password", "api-key", "apikey", "api_key"}
+
+// isSensitiveHeader tells whether the header is a secret, either by its name or because the
+// security definitions say it carries an api key. apiKeys holds the canonical header names.
+func isSensitiveHeader(name string, apiKeys map[string]bool) bool {
+	if apiKeys[http.CanonicalHeaderKey(name)] {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, s := range sensitiveHeaders {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote quotes the string so that it can be pasted into a POSIX shell as a single word.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// secretQueryParams returns the names of the query parameters that carry api keys according to
+// the security definitions in the swagger spec.
+func secretQueryParams(swagger *mqswag.Swagger) map[string]bool {
+	return apiKeyNames(swagger, "query", func(name string) string { return name })
+}
+
+// secretHeaders returns the canonical names of the headers that carry api keys according to
+// the security definitions in the swagger spec.
+func secretHeaders(swagger *mqswag.Swagger) map[string]bool {
+	return apiKeyNames(swagger, "header", http.CanonicalHeaderKey)
+}
+
+func apiKeyNames(swagger *mqswag.Swagger, in string, key func(string) string) map[string]bool {
+	secrets := make(map[string]bool)
+	if swagger == nil {
+		return secrets
+	}
+	for _, def := range swagger.SecurityDefinitions {
+		if def != nil && def.Type == "apiKey" && def.In == in {
+			secrets[key(def.Name)] = true
+		}
+	}
+	return secrets
+}
+
+// ResolvedPath returns the test's path with the path parameters filled in.
+func (t *Test) ResolvedPath() string {
+	path := t.Path
+	for k, v := range mqutil.MapInterfaceToMapString(t.PathParams) {
+		path = strings.Replace(path, "{"+k+"}", v, -1)
+	}
+	return path
+}
+
+// fileParams returns the names of the form parameters that are file uploads.
+func (t *Test) fileParams() map[string]bool {
+	files := make(map[string]bool)
+	if t.op == nil {
+		return files
+	}
+	for _, p := range t.op.Parameters {
+		if p.Type == "file" {
+			files[p.Name] = true
+		}
+	}
+	return files
+}
+
+// CurlCommand returns a curl command line that sends the same request as the test. It should be
+// called after the parameters are resolved. Unless showSecrets is set, the credentials and the
+// values of sensitive headers and api key headers and query parameters are masked.
+func (t *Test) CurlCommand(tc *TestSuite, showSecrets bool) string {
+	mask := func(value string, secret bool) string {
+		if secret && !showSecrets {
+			return maskedValue
+		}
+		return value
+	}
+	var swagger *mqswag.Swagger
+	if t.db != nil {
+		swagger = t.db.Swagger
+	}
+
+	args := []string{"curl"}
+	if t.Method == mqswag.MethodHead {
+		args = append(args, "-I")
+	} else {
+		args = append(args, "-X", strings.ToUpper(t.Method))
+	}
+
+	if tc != nil {
+		if len(tc.ApiToken) > 0 {
+			args = append(args, "-H", shellQuote("Authorization: Bearer "+mask(tc.ApiToken, true)))
+		} else if len(tc.Username) > 0 {
+			args = append(args, "-u", shellQuote(tc.Username+":"+mask(tc.Password, true)))
+		}
+	}
+
+	headers := mqutil.MapInterfaceToMapString(t.HeaderParams)
+	apiKeys := secretHeaders(swagger)
+	for _, k := range sortedKeys(headers) {
+		args = append(args, "-H", shellQuote(k+": "+mask(headers[k], isSensitiveHeader(k, apiKeys))))
+	}
+
+	if len(t.FormParams) > 0 {
+		files := t.fileParams()
+		form := mqutil.MapInterfaceToMapString(t.FormParams)
+		multipart := false
+		for k := range form {
+			if files[k] {
+				multipart = true
+			}
+		}
+		for _, k := range sortedKeys(form) {
+			if !multipart {
+				args = append(args, "--data-urlencode", shellQuote(k+"="+form[k]))
+			} else if files[k] {
+				args = append(args, "-F", shellQuote(k+"=@"+form[k]))
+			} else {
+				args = append(args, "-F", shellQuote(k+"="+form[k]))
+			}
+		}
+	} else if t.BodyParams != nil {
+		if str, ok := t.BodyParams.(string); ok {
+			args = append(args, "--data-raw", shellQuote(str))
+		} else {
+			body, err := json.Marshal(t.BodyParams)
+			if err == nil {
+				args = append(args, "-H", shellQuote("Content-Type: application/json"), "--data-raw", shellQuote(string(body)))
+			}
+		}
+	}
+
+	u := t.ResolvedPath()
+	if swagger != nil {
+		u = GetBaseURL(swagger) + u
+	}
+	if len(t.QueryParams) > 0 {
+		secrets := secretQueryParams(swagger)
+		values := url.Values{}
+		for k, v := range mqutil.MapInterfaceToMapString(t.QueryParams) {
+			values.Set(k, mask(v, secrets[k]))
+		}
+		u = u + "?" + values.Encode()
+	}
+	args = append(args, shellQuote(u))
+
+	return strings.Join(args, " ")
+}
+
+// printCurl prints the reproduction command of the test, if it has one.
+func (t *Test) printCurl() {
+	if len(t.curl) > 0 {
+		fmt.Println("Reproduce with:")
+		fmt.Println(t.curl)
+	}
+}
@@ -0,0 +1,40 @@
+package mqplan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/go-openapi/spec"
+)
+
+func TestCurlCommand(t *testing.T) {
+	swagger := &mqswag.Swagger{}
+	swagger.Host = "example.com"
+	swagger.BasePath = "/v2"
+	swagger.SecurityDefinitions = spec.SecurityDefinitions{"client": spec.APIKeyAuth("x-client-key", "header")}
+	db := &mqswag.DB{Swagger: swagger}
+	suite := &TestSuite{ApiToken: "secret-token"}
+
+	test := &Test{
+		Method: mqswag.MethodPost,
+		Path:   "/pet/{petId}",
+		db:     db,
+	}
+	test.PathParams = map[string]interface{}{"petId": 12}
+	test.QueryParams = map[string]interface{}{"status": "sold"}
+	test.HeaderParams = map[string]interface{}{"X-Api-Key": "abc", "X-Client-Key": "def", "X-Trace": "it's"}
+	test.BodyParams = map[string]interface{}{"name": "doggie"}
+
+	masked := test.CurlCommand(suite, false)
+	expected := `curl -X POST -H 'Authorization: Bearer ****' -H 'X-Api-Key: ****' -H 'X-Client-Key: ****' -H 'X-Trace: it'\''s' ` +
+		`-H 'Content-Type: application/json' --data-raw '{"name":"doggie"}' 'http://example.com/v2/pet/12?status=sold'`
+	if masked != expected {
+		t.Errorf("unexpected curl command:\n%s\nexpected:\n%s", masked, expected)
+	}
+
+	shown := test.CurlCommand(suite, true)
+	if !strings.Contains(shown, "Bearer secret-token") || !strings.Contains(shown, "X-Api-Key: abc") || !strings.Contains(shown, "X-Client-Key: def") {
+		t.Errorf("secrets should be shown: %s", shown)
+	}
+}
@@ -0,0 +1,99 @@
+package mqplan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+const (
+	DBDumpNever  = "never"  // No dumps.
+	DBDumpFail   = "fail"   // Dump after the failed tests.
+	DBDumpAlways = "always" // Dump after every test.
+)
+
+var DBDumpModes = []string{DBDumpNever, DBDumpFail, DBDumpAlways}
+
+// DBDump is what the client believed the server state was after a test.
+type DBDump struct {
+	Suite    string           `json:"suite"`
+	Test     string           `json:"test"`
+	Failed   bool             `json:"failed"`
+	Error    string           `json:"error,omitempty"`
+	SuiteDB  *mqswag.Snapshot `json:"suiteDB"`  // The objects of the test suite.
+	GlobalDB *mqswag.Snapshot `json:"globalDB"` // The objects of the whole run.
+}
+
+// DBDumper writes the DB state to a json file per test, in the order the tests run.
+type DBDumper struct {
+	OnlyFailures bool
+	Dir          string
+
+	count int
+	mutex sync.Mutex
+}
+
+// NewDBDumper creates the dumper writing to the directory for the mode, nil for DBDumpNever.
+func NewDBDumper(mode string, dir string) (*DBDumper, error) {
+	switch mode {
+	case DBDumpNever, "":
+		return nil, nil
+	case DBDumpFail, DBDumpAlways:
+	default:
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("unknown DB dump mode: %s", mode))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, mqutil.NewError(mqutil.ErrInternal, fmt.Sprintf("can't create the DB dump directory %s: %s", dir, err.Error()))
+	}
+	return &DBDumper{OnlyFailures: mode == DBDumpFail, Dir: dir}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// Dump writes the DB state after the test, if the mode asks for it. Returns the file written, if any.
+func (d *DBDumper) Dump(t *Test, suite *TestSuite, testErr error) (string, error) {
+	if d.OnlyFailures && testErr == nil {
+		return "", nil
+	}
+	dump := &DBDump{Suite: suite.Name, Test: t.Name, Failed: testErr != nil}
+	if testErr != nil {
+		dump.Error = testErr.Error()
+	}
+	if suite.db != nil {
+		dump.SuiteDB = suite.db.Snapshot()
+	}
+	if suite.plan != nil && suite.plan.db != nil {
+		dump.GlobalDB = suite.plan.db.Snapshot()
+	}
+	dumpBytes, err := mqutil.MarshalJsonIndentNoEscape(dump)
+	if err != nil {
+		return "", err
+	}
+
+	d.mutex.Lock()
+	d.count++
+	name := fmt.Sprintf("%04d_%s_%s.json", d.count, unsafeFileChars.ReplaceAllString(suite.Name, "_"),
+		unsafeFileChars.ReplaceAllString(t.Name, "_"))
+	d.mutex.Unlock()
+
+	path := filepath.Join(d.Dir, name)
+	if err = os.WriteFile(path, dumpBytes, 0644); err != nil {
+		return "", mqutil.NewError(mqutil.ErrInternal, fmt.Sprintf("can't write the DB dump %s: %s", path, err.Error()))
+	}
+	return path, nil
+}
+
+// SuiteDB returns the objects the test suite knows of, nil if the suite isn't running.
+func (tc *TestSuite) SuiteDB() *mqswag.DB {
+	return tc.db
+}
+
+// DB returns the objects the whole run knows of.
+func (plan *TestPlan) DB() *mqswag.DB {
+	return plan.db
+}
@@ -0,0 +1,68 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func TestDBDump(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	har := &Har{}
+	har.Log.Entries = []*HarEntry{
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 200, `{"id": 7, "name": "kitty"}`),
+	}
+	defer useTransport(NewReplayer(har))()
+
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+	plan := &TestPlan{}
+	plan.Init(swagger, db)
+	// As with -db-out, the suites share the global DB.
+	plan.SharedDB = true
+	dir := filepath.Join(t.TempDir(), "dbdump")
+	if plan.DBDumper, err = NewDBDumper(DBDumpFail, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err = plan.AddFromString(replayPlan); err != nil {
+		t.Fatal(err)
+	}
+	if counts, _ := plan.Run("pet lifecycle", nil); counts[mqutil.Failed] != 1 {
+		t.Fatalf("expected the strict read to fail, got %v", counts)
+	}
+
+	// Only the failed read is dumped, with the pet the client created.
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 || filepath.Base(files[0]) != "0001_pet_lifecycle_read.json" {
+		t.Fatalf("expected one dump for the read, got %v", files)
+	}
+	dumpBytes, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := &DBDump{}
+	if err = json.Unmarshal(dumpBytes, dump); err != nil {
+		t.Fatal(err)
+	}
+	if !dump.Failed || dump.SuiteDB == nil || len(dump.SuiteDB.Schemas["Pet"]) != 1 ||
+		dump.SuiteDB.Schemas["Pet"][0].Data["name"] != "doggie" {
+		t.Errorf("expected the suite DB to hold doggie, got %s", string(dumpBytes))
+	}
+	if pets := plan.DB().Entries("Pet"); len(pets) != 1 || pets[0].Data["name"] != "doggie" {
+		t.Errorf("expected the global DB to hold doggie, got %v", pets)
+	}
+
+	if _, err = NewDBDumper("sometimes", dir); err == nil {
+		t.Errorf("expected an unknown mode rejected")
+	}
+}
@@ -27,8 +27,9 @@ import (
 )
 
 const (
-	ExpectStatus = "status"
-	ExpectBody   = "body"
+	ExpectStatus   = "status"
+	ExpectBody     = "body"
+	ExpectContains = "contains" // The objects the array returned must have, matched like the body.
 )
 
 func GetBaseURL(swagger *mqswag.Swagger) string {
@@ -106,8 +107,35 @@ type Test struct {
 	Strict     bool                   `yaml:"strict,omitempty"`
 	TestParams `yaml:",inline,omitempty" json:",inline,omitempty"`
 
+	// Set when the operation is no longer in the swagger spec. Obsolete tests are skipped.
+	Obsolete bool `yaml:"obsolete,omitempty"`
+
+	// The names of the parameters left out of the request, instead of generated. Set by the pairwise algorithm.
+	Absent []string `yaml:"absent,omitempty"`
+
+	// Repeat the request until the response meets the condition.
+	WaitUntil *WaitUntil `yaml:"waitUntil,omitempty"`
+
+	// The request timeout, e.g. 10s, and the retry policy. The ones of the suite or the plan apply when unset.
+	Timeout string `yaml:"timeout,omitempty"`
+	Retry   *Retry `yaml:"retry,omitempty"`
+
+	// The max requests per second and requests in flight of the whole run. Only read from the plan's meqa_init.
+	RPS         float64 `yaml:"rps,omitempty"`
+	MaxInFlight int     `yaml:"maxInFlight,omitempty"`
+
+	// How the server is called over TLS. Only read from the plan's meqa_init.
+	TLS *TLS `yaml:"tls,omitempty"`
+
+	// The requests sent and the time spent waiting for the waitUntil condition. Only set on the tests in the result.
+	Attempts int    `yaml:"attempts,omitempty"`
+	WaitTime string `yaml:"waitTime,omitempty"`
+	// The requests sent again after a network error, a 5xx or a 429. Only set on the tests in the result.
+	Retries int `yaml:"retries,omitempty"`
+
 	startTime time.Time
 	stopTime  time.Time
+	curl      string // The curl command equivalent to the request sent.
 
 	// Map of Object name (matching definitions) to the Comparison object.
 	// This tracks what objects we need to add to DB at the end of test.
@@ -146,6 +174,12 @@ func (t *Test) Init(suite *TestSuite) {
 			mqutil.Logger.Print(err)
 		}
 	}
+	if len(t.Expect) > 0 && t.Expect[ExpectContains] != nil {
+		t.Expect[ExpectContains], err = mqutil.YamlObjToJsonObj(t.Expect[ExpectContains])
+		if err != nil {
+			mqutil.Logger.Print(err)
+		}
+	}
 }
 
 func (t *Test) Duplicate() *Test {
@@ -166,6 +200,9 @@ func (t *Test) Duplicate() *Test {
 	test.resp = nil
 	test.comparisons = make(map[string]([]*Comparison))
 	test.err = nil
+	test.Attempts = 0
+	test.WaitTime = ""
+	test.Retries = 0
 	test.db = test.suite.db
 
 	return &test
@@ -331,21 +368,41 @@ func (t *Test) CompareGetResult(className string, associations map[string]map[st
 	return nil
 }
 
+// planDB returns the DB shared by all the test suites of the plan, the one saved across the runs, nil
+// unless the plan shares it. During a run, t.db is the suite's copy.
+func (t *Test) planDB() *mqswag.DB {
+	if t.suite != nil && t.suite.plan != nil && t.suite.plan.SharedDB && t.suite.plan.db != nil && t.suite.plan.db != t.db {
+		return t.suite.plan.db
+	}
+	return nil
+}
+
 // ProcessOneComparison processes one comparison object.
 func (t *Test) ProcessOneComparison(className string, method string, comp *Comparison,
 	associations map[string]map[string]interface{}, collection map[string][]interface{}) error {
 
+	t.recordCreated(method, className, comp, associations)
+	planDB := t.planDB()
 	if method == mqswag.MethodDelete {
 		fmt.Printf("... deleting entry from client DB. Success\n")
 		t.suite.db.Delete(className, comp.oldUsed, associations, mqutil.InterfaceEquals, -1)
 		t.db.Delete(className, comp.oldUsed, associations, mqutil.InterfaceEquals, -1)
+		if planDB != nil {
+			planDB.Delete(className, comp.oldUsed, associations, mqutil.InterfaceEquals, -1)
+		}
 	} else if method == mqswag.MethodPost && comp.new != nil {
 		fmt.Printf("... adding entry to client DB. Success\n")
 		t.suite.db.Insert(className, comp.new, associations)
+		if planDB != nil {
+			planDB.Insert(className, comp.new, associations)
+		}
 		return t.db.Insert(className, comp.new, associations)
 	} else if (method == mqswag.MethodPatch || method == mqswag.MethodPut) && comp.new != nil {
 		fmt.Printf("... updating entry in client DB. Success\n")
 		t.suite.db.Update(className, comp.oldUsed, associations, mqutil.InterfaceEquals, comp.new, 1, method == mqswag.MethodPatch)
+		if planDB != nil {
+			planDB.Update(className, comp.oldUsed, associations, mqutil.InterfaceEquals, comp.new, 1, method == mqswag.MethodPatch)
+		}
 		count := t.db.Update(className, comp.oldUsed, associations, mqutil.InterfaceEquals, comp.new, 1, method == mqswag.MethodPatch)
 		if count != 1 {
 			mqutil.Logger.Printf("Failed to find any entry to update")
@@ -486,13 +543,25 @@ func (t *Test) ProcessResult(resp *resty.Response) error {
 				fmt.Printf("... checking body against test's expect value. Success\n")
 			} else {
 				mqutil.InterfacePrint(map[string]interface{}{"... expecting body": t.Expect[ExpectBody]}, true)
-				fmt.Printf("... actual response body: %s\n", respBody)
+				fmt.Printf("... actual response body: %s\n", respBody())
 				fmt.Printf("... checking body against test's expect value. Fail\n")
 				ejson, _ := json.Marshal(t.Expect[ExpectBody])
 				setExpect()
 				return mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf(
-					"=== test failed, expecting body: \n%s\ngot body:\n%s\n===", string(ejson), respBody))
+					"=== test failed, expecting body: \n%s\ngot body:\n%s\n===", string(ejson), respBody()))
+			}
+		}
+		if t.Expect != nil && t.Expect[ExpectContains] != nil {
+			if missing, ok := missingElement(t.Expect[ExpectContains], resultObj); ok {
+				mqutil.InterfacePrint(map[string]interface{}{"... expecting the response to contain": missing}, true)
+				fmt.Printf("... actual response body: %s\n", respBody())
+				fmt.Printf("... checking the response contains the test's expect values. Fail\n")
+				ejson, _ := json.Marshal(missing)
+				setExpect()
+				return mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf(
+					"=== test failed, expecting the response to contain: \n%s\ngot body:\n%s\n===", string(ejson), respBody()))
 			}
+			fmt.Printf("... checking the response contains the test's expect values. Success\n")
 		}
 	} else {
 		t.responseError = resp
@@ -672,9 +741,13 @@ func (t *Test) ProcessResult(resp *resty.Response) error {
 
 	if !t.Strict {
 		// Add everything from the collection to the in-mem DB
+		planDB := t.planDB()
 		for className, classList := range collection {
 			for _, entry := range classList {
 				t.db.Insert(className, entry, associations)
+				if planDB != nil {
+					planDB.Insert(className, entry, associations)
+				}
 			}
 		}
 	}
@@ -718,21 +791,25 @@ func (t *Test) SetRequestParameters(req *resty.Request) string {
 		req.SetHeaders(mqutil.MapInterfaceToMapString(t.HeaderParams))
 		mqutil.InterfacePrint(map[string]interface{}{"headerParams": t.HeaderParams}, mqutil.Verbose)
 	}
-	path := t.Path
 	if len(t.PathParams) > 0 {
-		PathParamsStr := mqutil.MapInterfaceToMapString(t.PathParams)
-		for k, v := range PathParamsStr {
-			path = strings.Replace(path, "{"+k+"}", v, -1)
-		}
 		mqutil.InterfacePrint(map[string]interface{}{"pathParams": t.PathParams}, mqutil.Verbose)
 	}
-	return path
+	return t.ResolvedPath()
 }
 
 func (t *Test) CopyParent(parentTest *Test) {
 	if parentTest != nil {
 		t.Strict = parentTest.Strict
 		t.Expect = mqutil.MapCopy(parentTest.Expect)
+		if t.WaitUntil == nil {
+			t.WaitUntil = parentTest.WaitUntil
+		}
+		if len(t.Timeout) == 0 {
+			t.Timeout = parentTest.Timeout
+		}
+		if t.Retry == nil {
+			t.Retry = parentTest.Retry
+		}
 		t.QueryParams = mqutil.MapAdd(t.QueryParams, parentTest.QueryParams)
 		t.PathParams = mqutil.MapAdd(t.PathParams, parentTest.PathParams)
 		t.HeaderParams = mqutil.MapAdd(t.HeaderParams, parentTest.HeaderParams)
@@ -769,46 +846,24 @@ func (t *Test) Run(tc *TestSuite) error {
 		return err
 	}
 
-	req := resty.R()
-	if len(tc.ApiToken) > 0 {
-		req.SetAuthToken(tc.ApiToken)
-	} else if len(tc.Username) > 0 {
-		req.SetBasicAuth(tc.Username, tc.Password)
-	}
-
-	path := GetBaseURL(t.db.Swagger) + t.SetRequestParameters(req)
+	t.curl = t.CurlCommand(tc, tc.plan != nil && tc.plan.ShowSecrets)
 	var resp *resty.Response
-
-	t.startTime = time.Now()
-	switch t.Method {
-	case mqswag.MethodGet:
-		resp, err = req.Get(path)
-	case mqswag.MethodPost:
-		resp, err = req.Post(path)
-	case mqswag.MethodPut:
-		resp, err = req.Put(path)
-	case mqswag.MethodDelete:
-		resp, err = req.Delete(path)
-	case mqswag.MethodPatch:
-		resp, err = req.Patch(path)
-	case mqswag.MethodHead:
-		resp, err = req.Head(path)
-	case mqswag.MethodOptions:
-		resp, err = req.Options(path)
-	default:
-		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("Unknown method in test %s: %v", t.Name, t.Method))
+	held := true
+	if t.WaitUntil != nil {
+		resp, held, err = t.poll(tc)
+	} else {
+		resp, err = t.send(tc)
 	}
-	log.Println(path, resp)
-	t.stopTime = time.Now()
-	fmt.Printf("... call completed: %f seconds\n", t.stopTime.Sub(t.startTime).Seconds())
-
 	if err != nil {
-		t.err = mqutil.NewError(mqutil.ErrHttp, err.Error())
-	} else {
-		mqutil.Logger.Print(resp.Status())
-		mqutil.Logger.Println(string(resp.Body()))
+		fmt.Printf("... Fail\n... %s\n", err.Error())
+		return err
 	}
 	err = t.ProcessResult(resp)
+	if err == nil && !held {
+		fmt.Printf("... waitUntil condition not met. Fail\n")
+		return mqutil.NewError(mqutil.ErrExpect, fmt.Sprintf(
+			"=== test failed, waitUntil condition not met after %d attempts in %s ===", t.Attempts, t.WaitTime))
+	}
 	return err
 }
 
@@ -867,6 +922,52 @@ func (t *Test) ResolveHistoryParameters(h *TestHistory) {
 			t.BodyParams = result
 		}
 	}
+	// The expected body can refer to the earlier tests too. It's copied first as it's shared with the test plan.
+	if expectMap, ok := t.Expect[ExpectBody].(map[string]interface{}); ok {
+		expectMap = mqutil.MapCopy(expectMap)
+		MapParamsResolveWithHistory(expectMap, h)
+		t.Expect[ExpectBody] = expectMap
+	}
+	if expectList, ok := t.Expect[ExpectContains].([]interface{}); ok {
+		expectList = mqutil.ArrayCopy(expectList)
+		ArrayParamsResolveWithHistory(expectList, h)
+		t.Expect[ExpectContains] = expectList
+	} else if expectMap, ok := t.Expect[ExpectContains].(map[string]interface{}); ok {
+		expectMap = mqutil.MapCopy(expectMap)
+		MapParamsResolveWithHistory(expectMap, h)
+		t.Expect[ExpectContains] = expectMap
+	}
+	if t.WaitUntil != nil {
+		if bodyMap, ok := t.WaitUntil.Body.(map[string]interface{}); ok {
+			waitUntil := *t.WaitUntil
+			waitUntil.Body = mqutil.MapCopy(bodyMap)
+			MapParamsResolveWithHistory(waitUntil.Body.(map[string]interface{}), h)
+			t.WaitUntil = &waitUntil
+		}
+	}
+}
+
+// missingElement returns the first of the expected objects the array returned doesn't have. The expected
+// objects are a list, or a single object.
+func missingElement(expected interface{}, resultObj interface{}) (interface{}, bool) {
+	expectedList, ok := expected.([]interface{})
+	if !ok {
+		expectedList = []interface{}{expected}
+	}
+	resultList, _ := resultObj.([]interface{})
+	for _, e := range expectedList {
+		found := false
+		for _, r := range resultList {
+			if mqutil.InterfaceEquals(e, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return e, true
+		}
+	}
+	return nil, false
 }
 
 // ParamsAdd adds the parameters from src to dst if the param doesn't already exist on dst.
@@ -890,6 +991,16 @@ func ParamsAdd(dst []spec.Parameter, src []spec.Parameter) []spec.Parameter {
 	return dst
 }
 
+// isAbsent tells whether the parameter is to be left out of the request.
+func (t *Test) isAbsent(name string) bool {
+	for _, a := range t.Absent {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
 // ResolveParameters fullfills the parameters for the specified request using the in-mem DB.
 // The resolved parameters will be added to test.Parameters map.
 func (t *Test) ResolveParameters(tc *TestSuite) error {
@@ -975,6 +1086,11 @@ func (t *Test) ResolveParameters(tc *TestSuite) error {
 				globalParamsMap = tc.FormParams
 			}
 
+			if t.isAbsent(params.Name) {
+				delete(paramsMap, params.Name)
+				fmt.Print("absent\n")
+				continue
+			}
 			// If there is a parameter passed in, just use it. Otherwise generate one.
 			_, inLocal := paramsMap[params.Name]
 			_, inGlobal := globalParamsMap[params.Name]
@@ -1098,6 +1214,9 @@ func (t *Test) generateByType(s *spec.Schema, prefix string, parentTag *mqswag.M
 			if len(ar) == 0 {
 				ar = t.db.Find(tag.Class, nil, nil, mqswag.MatchAlways, 5)
 			}
+			if planDB := t.planDB(); len(ar) == 0 && planDB != nil {
+				ar = planDB.Find(tag.Class, nil, nil, mqswag.MatchAlways, 5)
+			}
 			if len(ar) > 0 {
 				obj := ar[rand.Intn(len(ar))].(map[string]interface{})
 				comp := &Comparison{obj, make(map[string]interface{}), nil, (*spec.Schema)(t.db.GetSchema(tag.Class))}
@@ -1388,6 +1507,9 @@ func (t *Test) GenerateSchema(name string, parentTag *mqswag.MeqaTag, schema *sp
 			if len(found) == 0 {
 				found = t.db.Find(referenceName, nil, nil, mqswag.MatchAlways, 1)
 			}
+			if planDB := t.planDB(); len(found) == 0 && planDB != nil {
+				found = planDB.Find(referenceName, nil, nil, mqswag.MatchAlways, 1)
+			}
 			if len(found) > 0 {
 				if level != 0 {
 					fmt.Printf("found %s\n", referenceName)
@@ -1456,6 +1578,17 @@ func (t *Test) GenerateSchema(name string, parentTag *mqswag.MeqaTag, schema *sp
 	return t.generateByType(schema, name, tag, nil, level != 0)
 }
 
+// GenerateValue generates a value for the schema outside of a test run, e.g. the response of a mock
+// server. The fields that refer to other objects are filled with the objects found in db.
+func GenerateValue(schema *spec.Schema, db *mqswag.DB) (interface{}, error) {
+	plan := &TestPlan{}
+	plan.Init(db.Swagger, db)
+	suite := CreateTestSuite("", nil, plan)
+	suite.db = db
+	t := &Test{suite: suite, db: db, comparisons: make(map[string]([]*Comparison))}
+	return t.GenerateSchema("", nil, schema, db, 0)
+}
+
 func generateEnum(e []interface{}) (interface{}, error) {
 	return e[rand.Intn(len(e))], nil
 }
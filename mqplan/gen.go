@@ -2,9 +2,10 @@ package mqplan
 
 import (
 	"fmt"
-
+	"math/rand"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/gbatanov/meqa/mqswag"
 	"github.com/gbatanov/meqa/mqutil"
@@ -77,8 +78,9 @@ func OperationMatches(node *mqswag.DAGNode, method string) bool {
 }
 
 // GenerateTestsForObject for the obj that we traversed to from create. Add the test suites
-// generated to plan.
-func GenerateTestsForObject(create *mqswag.DAGNode, obj *mqswag.DAGNode, plan *TestPlan) error {
+// generated to plan. Only the operations selected by the filter are tested, but the create
+// operation is always there to create the object.
+func GenerateTestsForObject(create *mqswag.DAGNode, obj *mqswag.DAGNode, plan *TestPlan, filter *mqswag.OperationFilter) error {
 	if obj.GetType() != mqswag.TypeDef {
 		return nil
 	}
@@ -92,10 +94,12 @@ func GenerateTestsForObject(create *mqswag.DAGNode, obj *mqswag.DAGNode, plan *T
 	testId := 1
 	testSuite := CreateTestSuite(fmt.Sprintf("%s -- %s -- all", createPath, objName), nil, plan)
 	testSuite.Tests = append(testSuite.Tests, CreateTestFromOp(create, testId))
+	selected := filter.MatchesNode(create)
 	for _, child := range obj.Children {
-		if child.GetType() != mqswag.TypeOp {
+		if child.GetType() != mqswag.TypeOp || !filter.MatchesNode(child) {
 			continue
 		}
+		selected = true
 		testId++
 		testSuite.Tests = append(testSuite.Tests, CreateTestFromOp(child, testId))
 		if OperationMatches(child, mqswag.MethodDelete) {
@@ -103,36 +107,14 @@ func GenerateTestsForObject(create *mqswag.DAGNode, obj *mqswag.DAGNode, plan *T
 			testSuite.Tests = append(testSuite.Tests, CreateTestFromOp(create, testId))
 		}
 	}
-	if len(testSuite.Tests) > 0 {
-		plan.Add(testSuite)
-	}
-
-	// a loop where we pick random operations and pair it with the create operation.
-	// This would generate a few objects.
-	/* disable random stuff during development
-	testId = 0
-	testSuite = &TestSuite{nil, fmt.Sprintf("%s -- %s -- random", createPath, objName)}
-	for i := 0; i < 2*len(obj.Children); i++ {
-		j := rand.Intn(len(obj.Children))
-		child := obj.Children[j]
-		if child.GetType() != mqswag.TypeOp {
-			mqutil.Logger.Printf("unexpected: (%s) has a child (%s) that's not an operation", obj.Name, child.Name)
-			continue
-		}
-		testId++
-		testSuite.Tests = append(testSuite.Tests, CreateTestFromOp(create, testId))
-		testId++
-		testSuite.Tests = append(testSuite.Tests, CreateTestFromOp(child, testId))
-	}
-	if len(testSuite.Tests) > 0 {
+	if selected {
 		plan.Add(testSuite)
 	}
-	*/
 
 	return nil
 }
 
-func GenerateTestPlan(swagger *mqswag.Swagger, dag *mqswag.DAG) (*TestPlan, error) {
+func GenerateTestPlan(swagger *mqswag.Swagger, dag *mqswag.DAG, filter *mqswag.OperationFilter) (*TestPlan, error) {
 	testPlan := &TestPlan{}
 	testPlan.Init(swagger, nil)
 	testPlan.comment = `
@@ -155,7 +137,7 @@ then exercise REST calls that use that object as an input.
 
 		// When iterating by weight previous is always nil.
 		for _, c := range current.Children {
-			err := GenerateTestsForObject(current, c, testPlan)
+			err := GenerateTestsForObject(current, c, testPlan, filter)
 			if err != nil {
 				return err
 			}
@@ -171,8 +153,26 @@ then exercise REST calls that use that object as an input.
 }
 
 // All the operations have the same path. We generate one test suite, with the
-// tests of ascending weight and priority among the operations
-func GeneratePathTestSuite(operations mqswag.NodeList, plan *TestPlan) {
+// tests of ascending weight and priority among the operations. Only the operations selected
+// by the filter are tested, together with the post operations that create the objects.
+func GeneratePathTestSuite(operations mqswag.NodeList, plan *TestPlan, filter *mqswag.OperationFilter) {
+	if !filter.IsEmpty() {
+		var selected mqswag.NodeList
+		for _, o := range operations {
+			if filter.MatchesNode(o) {
+				selected = append(selected, o)
+			}
+		}
+		if len(selected) == 0 {
+			return
+		}
+		for _, o := range operations {
+			if !filter.MatchesNode(o) && OperationMatches(o, mqswag.MethodPost) {
+				selected = append(selected, o)
+			}
+		}
+		operations = selected
+	}
 	if len(operations) == 0 {
 		return
 	}
@@ -221,8 +221,9 @@ func GeneratePathTestSuite(operations mqswag.NodeList, plan *TestPlan) {
 }
 
 type PathWeight struct {
-	path   string
-	weight int
+	path     string
+	weight   int
+	priority int
 }
 
 type PathWeightList []PathWeight
@@ -236,12 +237,18 @@ func (n PathWeightList) Swap(i, j int) {
 }
 
 func (n PathWeightList) Less(i, j int) bool {
-	return n[i].weight < n[j].weight || (n[i].weight == n[j].weight && n[i].path < n[j].path)
+	if n[i].weight != n[j].weight {
+		return n[i].weight < n[j].weight
+	}
+	if n[i].priority != n[j].priority {
+		return n[i].priority < n[j].priority
+	}
+	return n[i].path < n[j].path
 }
 
 // Go through all the paths in swagger, and generate the tests for all the operations under
 // the path.
-func GeneratePathTestPlan(swagger *mqswag.Swagger, dag *mqswag.DAG, whitelist map[string]bool) (*TestPlan, error) {
+func GeneratePathTestPlan(swagger *mqswag.Swagger, dag *mqswag.DAG, whitelist map[string]bool, filter *mqswag.OperationFilter) (*TestPlan, error) {
 	testPlan := &TestPlan{}
 	testPlan.Init(swagger, nil)
 	testPlan.comment = `
@@ -252,7 +259,7 @@ parameters by default.
 	addInitTestSuite(testPlan)
 
 	pathMap := make(map[string]mqswag.NodeList)
-	pathWeight := make(map[string]int)
+	pathWeight := make(map[string]PathWeight)
 
 	addFunc := func(previous *mqswag.DAGNode, current *mqswag.DAGNode) error {
 		if current.GetType() != mqswag.TypeOp {
@@ -275,9 +282,10 @@ parameters by default.
 
 		pathMap[name] = append(pathMap[name], current)
 
-		currentWeight := current.Weight*mqswag.DAGDepth + current.Priority
-		if pathWeight[name] <= currentWeight {
-			pathWeight[name] = currentWeight
+		// The path goes after all of its operations' dependencies.
+		w := PathWeight{name, current.Weight, current.Priority}
+		if p, ok := pathWeight[name]; !ok || PathWeightList([]PathWeight{p, w}).Less(0, 1) {
+			pathWeight[name] = w
 		}
 
 		return nil
@@ -287,49 +295,127 @@ parameters by default.
 
 	var pathWeightList PathWeightList
 	// Sort the path by weight
-	for k, v := range pathWeight {
-		p := PathWeight{k, v}
+	for _, p := range pathWeight {
 		pathWeightList = append(pathWeightList, p)
 	}
 	sort.Sort(pathWeightList)
 
 	for _, p := range pathWeightList {
 		if whitelist == nil || whitelist[p.path] {
-			GeneratePathTestSuite(pathMap[p.path], testPlan)
+			GeneratePathTestSuite(pathMap[p.path], testPlan, filter)
 		}
 	}
 	return testPlan, nil
 }
 
-// Go through all the paths in swagger, and generate the tests for all the operations under
-// the path.
-func GenerateSimpleTestPlan(swagger *mqswag.Swagger, dag *mqswag.DAG) (*TestPlan, error) {
+const (
+	SimpleByWeight = "weight" // The lightest operations, i.e. the ones with the fewest dependencies.
+	SimpleRandom   = "random" // Random operations.
+	SimpleByTag    = "tag"    // The lightest operation of every tag.
+	SimpleByPath   = "path"   // The lightest operation of every path.
+
+	DefaultSimpleSize = 10
+)
+
+var SimpleStrategies = []string{SimpleByWeight, SimpleRandom, SimpleByTag, SimpleByPath}
+
+// sampleOperations picks up to size operations out of the ones given in weight order, using the strategy.
+// The operations picked stay in weight order. A size of 0 or less means no limit.
+func sampleOperations(operations mqswag.NodeList, size int, strategy string, seed int64) (mqswag.NodeList, error) {
+	if size <= 0 || size > len(operations) {
+		size = len(operations)
+	}
+	var picked mqswag.NodeList
+	switch strategy {
+	case SimpleByWeight, "":
+		picked = operations[:size]
+	case SimpleRandom:
+		chosen := make(map[int]bool)
+		for _, i := range rand.New(rand.NewSource(seed)).Perm(len(operations))[:size] {
+			chosen[i] = true
+		}
+		for i, o := range operations {
+			if chosen[i] {
+				picked = append(picked, o)
+			}
+		}
+	case SimpleByTag, SimpleByPath:
+		covered := make(map[string]bool)
+		for _, o := range operations {
+			if len(picked) >= size {
+				break
+			}
+			var groups []string
+			if strategy == SimpleByPath {
+				groups = []string{o.GetName()}
+			} else if op, ok := o.Data.(*spec.Operation); ok && op != nil && len(op.Tags) > 0 {
+				groups = op.Tags
+			} else {
+				groups = []string{""} // all the operations without tags
+			}
+			isNew := false
+			for _, g := range groups {
+				if !covered[g] {
+					isNew = true
+					covered[g] = true
+				}
+			}
+			if isNew {
+				picked = append(picked, o)
+			}
+		}
+	default:
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("unknown simple plan strategy: %s", strategy))
+	}
+	return picked, nil
+}
+
+// GenerateSimpleTestPlan samples up to size operations into one test suite, using the strategy. The seed
+// is used by the random strategy, a seed of 0 means a new random seed every time.
+func GenerateSimpleTestPlan(swagger *mqswag.Swagger, dag *mqswag.DAG, filter *mqswag.OperationFilter, size int,
+	strategy string, seed int64) (*TestPlan, error) {
+
 	testPlan := &TestPlan{}
 	testPlan.Init(swagger, nil)
 	addInitTestSuite(testPlan)
 
-	testId := 0
-	testSuite := CreateTestSuite(fmt.Sprintf("simple test suite"), nil, testPlan)
-	testSuite.comment = "The meqa_init task within a test suite initializes parameters that are applied to all tests within this suite"
-	testSuite.Tests = append(testSuite.Tests, createInitTask())
+	var operations mqswag.NodeList
 	addFunc := func(previous *mqswag.DAGNode, current *mqswag.DAGNode) error {
-		if testId >= 10 {
-			return mqutil.NewError(mqutil.ErrOK, "done")
-		}
-
-		if current.GetType() != mqswag.TypeOp {
-			return nil
+		if current.GetType() == mqswag.TypeOp && filter.MatchesNode(current) {
+			operations = append(operations, current)
 		}
-
-		testId++
-		testSuite.Tests = append(testSuite.Tests, CreateTestFromOp(current, testId))
-
 		return nil
 	}
-
 	dag.IterateByWeight(addFunc)
+
+	if strategy == SimpleRandom && seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	picked, err := sampleOperations(operations, size, strategy, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	testSuite := CreateTestSuite(fmt.Sprintf("simple test suite"), nil, testPlan)
+	testSuite.comment = "The meqa_init task within a test suite initializes parameters that are applied to all tests within this suite"
+	testSuite.Tests = append(testSuite.Tests, createInitTask())
+	for i, o := range picked {
+		testSuite.Tests = append(testSuite.Tests, CreateTestFromOp(o, i+1))
+	}
 	testPlan.Add(testSuite)
-	testPlan.comment = "\nThis is a simple and short test plan. We just sampled up to 10 REST calls into one test suite.\n"
+
+	sampled := fmt.Sprintf("%d of the %d", len(picked), len(operations))
+	switch strategy {
+	case SimpleRandom:
+		sampled += fmt.Sprintf(" REST calls at random (seed %d)", seed)
+	case SimpleByTag:
+		sampled += " REST calls, one for every tag,"
+	case SimpleByPath:
+		sampled += " REST calls, one for every path,"
+	default:
+		sampled += " REST calls with the fewest dependencies"
+	}
+	testPlan.comment = fmt.Sprintf("\nThis is a simple and short test plan. We sampled %s into one test suite.\n", sampled)
 
 	return testPlan, nil
 }
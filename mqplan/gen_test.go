@@ -0,0 +1,58 @@
+package mqplan
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func TestSimplePlanStrategies(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := mqswag.NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	dag.Sort()
+
+	operations := func(size int, strategy string, seed int64) []string {
+		plan, err := GenerateSimpleTestPlan(swagger, dag, nil, size, strategy, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ops []string
+		for _, test := range plan.SuiteMap["simple test suite"].Tests {
+			if len(test.Path) > 0 {
+				ops = append(ops, mqswag.OperationKey(test.Method, test.Path))
+			}
+		}
+		return ops
+	}
+
+	if ops := operations(0, SimpleByWeight, 0); len(ops) != 8 {
+		t.Errorf("a size of 0 should take all the 8 operations, got %v", ops)
+	}
+	if ops := operations(2, SimpleByWeight, 0); len(ops) != 2 || ops[0] != "get /pet/findByStatus" {
+		t.Errorf("expected the 2 lightest operations, got %v", ops)
+	}
+	first := operations(4, SimpleRandom, 42)
+	second := operations(4, SimpleRandom, 42)
+	if len(first) != 4 || fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("the same seed should pick the same operations, got %v and %v", first, second)
+	}
+	if ops := operations(10, SimpleByTag, 0); len(ops) != 2 {
+		t.Errorf("expected one operation for each of the pet and store tags, got %v", ops)
+	}
+	if ops := operations(10, SimpleByPath, 0); len(ops) != 5 {
+		t.Errorf("expected one operation for each of the 5 paths, got %v", ops)
+	}
+	if _, err := GenerateSimpleTestPlan(swagger, dag, nil, 10, "bogus", 0); err == nil {
+		t.Errorf("an unknown strategy should fail")
+	}
+}
@@ -0,0 +1,284 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+	"gopkg.in/resty.v1"
+)
+
+// The types below follow the HTTP Archive (HAR) 1.2 format, see http://www.softwareishard.com/blog/har-12-spec/
+// Fields starting with an underscore are meqa's custom fields, which HAR allows.
+
+type Har struct {
+	Log HarLog `json:"log"`
+}
+
+type HarLog struct {
+	Version string      `json:"version"`
+	Creator HarCreator  `json:"creator"`
+	Entries []*HarEntry `json:"entries"`
+}
+
+type HarCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HarEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HarRequest  `json:"request"`
+	Response        HarResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HarTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+
+	// The name of the test that sent the request, and the path template of its operation.
+	Test string `json:"_test,omitempty"`
+	Path string `json:"_path,omitempty"`
+}
+
+type HarNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HarPostParam struct {
+	Name     string `json:"name"`
+	Value    string `json:"value,omitempty"`
+	FileName string `json:"fileName,omitempty"`
+}
+
+type HarPostData struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []HarPostParam `json:"params,omitempty"`
+}
+
+type HarRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HarNameValue `json:"cookies"`
+	Headers     []HarNameValue `json:"headers"`
+	QueryString []HarNameValue `json:"queryString"`
+	PostData    *HarPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HarContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+}
+
+type HarResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HarNameValue `json:"cookies"`
+	Headers     []HarNameValue `json:"headers"`
+	Content     HarContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HarTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HarRecorder collects the request/response exchanges of a run. It's safe for concurrent use.
+type HarRecorder struct {
+	har   Har
+	mutex sync.Mutex
+}
+
+func NewHarRecorder() *HarRecorder {
+	r := &HarRecorder{}
+	r.har.Log.Version = "1.2"
+	r.har.Log.Creator = HarCreator{"meqa", "1.0"}
+	r.har.Log.Entries = []*HarEntry{}
+	return r
+}
+
+func harHeaders(header http.Header, apiKeys map[string]bool, showSecrets bool) []HarNameValue {
+	list := []HarNameValue{}
+	var names []string
+	for k := range header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range header[k] {
+			if isSensitiveHeader(k, apiKeys) && !showSecrets {
+				v = maskedValue
+			}
+			list = append(list, HarNameValue{k, v})
+		}
+	}
+	return list
+}
+
+// maskQuery returns a copy of the query values with the secret ones masked.
+func maskQuery(values url.Values, secrets map[string]bool, showSecrets bool) (url.Values, bool) {
+	masked := url.Values{}
+	changed := false
+	for k, vs := range values {
+		for _, v := range vs {
+			if secrets[k] && !showSecrets {
+				v = maskedValue
+				changed = true
+			}
+			masked.Add(k, v)
+		}
+	}
+	return masked, changed
+}
+
+func harValues(values url.Values) []HarNameValue {
+	list := []HarNameValue{}
+	var names []string
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range values[k] {
+			list = append(list, HarNameValue{k, v})
+		}
+	}
+	return list
+}
+
+// harCookies lists the cookies, masked like the Cookie and Set-Cookie headers they come from.
+func harCookies(cookies []*http.Cookie, showSecrets bool) []HarNameValue {
+	list := []HarNameValue{}
+	for _, c := range cookies {
+		v := c.Value
+		if !showSecrets {
+			v = maskedValue
+		}
+		list = append(list, HarNameValue{c.Name, v})
+	}
+	return list
+}
+
+// harPostData reconstructs the body the test sent.
+func (t *Test) harPostData(req *resty.Request) *HarPostData {
+	files := t.fileParams()
+	if len(req.FormData) > 0 || len(files) > 0 {
+		data := &HarPostData{MimeType: "application/x-www-form-urlencoded", Text: req.FormData.Encode()}
+		for _, p := range harValues(req.FormData) {
+			data.Params = append(data.Params, HarPostParam{Name: p.Name, Value: p.Value})
+		}
+		for k := range files {
+			if fname, ok := t.FormParams[k].(string); ok {
+				data.MimeType = "multipart/form-data"
+				data.Text = ""
+				data.Params = append(data.Params, HarPostParam{Name: k, FileName: fname})
+			}
+		}
+		return data
+	}
+	if req.Body == nil {
+		return nil
+	}
+	if str, ok := req.Body.(string); ok {
+		return &HarPostData{MimeType: "text/plain", Text: str}
+	}
+	body, err := json.Marshal(req.Body)
+	if err != nil {
+		return nil
+	}
+	return &HarPostData{MimeType: "application/json", Text: string(body)}
+}
+
+// Record adds the exchange of the test to the recording.
+func (r *HarRecorder) Record(t *Test, resp *resty.Response, showSecrets bool) {
+	if resp == nil || resp.Request == nil || resp.Request.RawRequest == nil {
+		return
+	}
+	req := resp.Request
+	rawReq := req.RawRequest
+	entry := &HarEntry{}
+	entry.Test = t.Name
+	entry.Path = t.Path
+	entry.StartedDateTime = req.Time.Format(time.RFC3339Nano)
+	elapsed := float64(t.stopTime.Sub(t.startTime)) / float64(time.Millisecond)
+	if rawResp := resp.RawResponse; rawResp != nil {
+		elapsed = float64(resp.Time()) / float64(time.Millisecond)
+	}
+	entry.Time = elapsed
+	entry.Timings = HarTimings{0, elapsed, 0}
+
+	// The api keys in the query and the headers are masked the same as in the curl command.
+	var swagger *mqswag.Swagger
+	if t.db != nil {
+		swagger = t.db.Swagger
+	}
+	query, masked := maskQuery(rawReq.URL.Query(), secretQueryParams(swagger), showSecrets)
+	reqURL := *rawReq.URL
+	if masked {
+		reqURL.RawQuery = query.Encode()
+	}
+
+	entry.Request.Method = rawReq.Method
+	entry.Request.URL = reqURL.String()
+	entry.Request.HTTPVersion = rawReq.Proto
+	entry.Request.Cookies = harCookies(rawReq.Cookies(), showSecrets)
+	entry.Request.Headers = harHeaders(rawReq.Header, secretHeaders(swagger), showSecrets)
+	entry.Request.QueryString = harValues(query)
+	entry.Request.PostData = t.harPostData(req)
+	entry.Request.HeadersSize = -1
+	entry.Request.BodySize = -1
+	if entry.Request.PostData != nil {
+		entry.Request.BodySize = len(entry.Request.PostData.Text)
+	}
+
+	entry.Response.Cookies = []HarNameValue{}
+	entry.Response.Headers = []HarNameValue{}
+	entry.Response.HeadersSize = -1
+	if rawResp := resp.RawResponse; rawResp != nil {
+		body := resp.Body()
+		entry.Response.Status = rawResp.StatusCode
+		entry.Response.StatusText = strings.TrimSpace(strings.TrimPrefix(rawResp.Status, strconv.Itoa(rawResp.StatusCode)))
+		entry.Response.HTTPVersion = rawResp.Proto
+		entry.Response.Cookies = harCookies(rawResp.Cookies(), showSecrets)
+		entry.Response.Headers = harHeaders(rawResp.Header, secretHeaders(swagger), showSecrets)
+		entry.Response.RedirectURL = rawResp.Header.Get("Location")
+		entry.Response.Content = HarContent{len(body), rawResp.Header.Get("Content-Type"), string(body)}
+		entry.Response.BodySize = len(body)
+	} else {
+		// The request never got a response, e.g. the connection failed.
+		entry.Comment = "no response received"
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.har.Log.Entries = append(r.har.Log.Entries, entry)
+}
+
+// WriteToFile writes the recording to the HAR file at path.
+func (r *HarRecorder) WriteToFile(path string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	harBytes, err := mqutil.MarshalJsonIndentNoEscape(&r.har)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, harBytes, 0644)
+}
@@ -0,0 +1,155 @@
+package mqplan
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+	"github.com/go-openapi/spec"
+)
+
+// recordExchange sends a request with secrets in the query, the headers and the cookies to a real server,
+// and returns the HAR file recorded.
+func recordExchange(t *testing.T, showSecrets bool) ([]byte, *Har) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "server-session"})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 1, "name": "doggie"}`))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+
+	swagger := &mqswag.Swagger{}
+	swagger.Host = u.Host
+	swagger.BasePath = "/v2"
+	swagger.SecurityDefinitions = spec.SecurityDefinitions{
+		"key":    spec.APIKeyAuth("api_key", "query"),
+		"client": spec.APIKeyAuth("X-Client-Key", "header"),
+	}
+
+	plan := &TestPlan{Recorder: NewHarRecorder(), ShowSecrets: showSecrets}
+	suite := &TestSuite{Name: "har", ApiToken: "secret-token", plan: plan}
+	test := &Test{Name: "create", Method: mqswag.MethodPost, Path: "/pet", db: &mqswag.DB{Swagger: swagger}, op: &spec.Operation{}}
+	test.QueryParams = map[string]interface{}{"api_key": "secret-key", "status": "sold"}
+	test.HeaderParams = map[string]interface{}{"Cookie": "session=client-session", "X-Client-Key": "secret-client", "X-Trace": "abc"}
+	test.BodyParams = map[string]interface{}{"name": "doggie"}
+	if _, err := test.send(suite); err != nil || test.err != nil {
+		t.Fatalf("the request failed: %v %v", err, test.err)
+	}
+
+	path := filepath.Join(t.TempDir(), "run.har")
+	if err := plan.Recorder.WriteToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	harBytes, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	har := &Har{}
+	if err = json.Unmarshal(harBytes, har); err != nil {
+		t.Fatal(err)
+	}
+	if len(har.Log.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(har.Log.Entries))
+	}
+	return harBytes, har
+}
+
+func nameValue(list []HarNameValue, name string) (string, bool) {
+	for _, nv := range list {
+		if strings.EqualFold(nv.Name, name) {
+			return nv.Value, true
+		}
+	}
+	return "", false
+}
+
+func TestHarRecorder(t *testing.T) {
+	harBytes, har := recordExchange(t, false)
+
+	// The fields HAR 1.2 requires.
+	var raw map[string]map[string]interface{}
+	json.Unmarshal(harBytes, &raw)
+	entries, _ := raw["log"]["entries"].([]interface{})
+	if len(entries) != 1 {
+		t.Fatalf("expected the entries in the log, got %s", string(harBytes))
+	}
+	for _, field := range []string{"startedDateTime", "time", "request", "response", "cache", "timings"} {
+		if _, ok := entries[0].(map[string]interface{})[field]; !ok {
+			t.Errorf("entry field %s missing", field)
+		}
+	}
+	if har.Log.Version != "1.2" || har.Log.Creator.Name != "meqa" {
+		t.Errorf("unexpected log header %v %v", har.Log.Version, har.Log.Creator)
+	}
+
+	e := har.Log.Entries[0]
+	if e.Test != "create" || e.Path != "/pet" || e.Request.Method != "POST" {
+		t.Errorf("unexpected entry %s %s %s", e.Test, e.Path, e.Request.Method)
+	}
+	if e.Request.PostData == nil || e.Request.PostData.Text != `{"name":"doggie"}` {
+		t.Errorf("unexpected post data %v", e.Request.PostData)
+	}
+	if e.Response.Status != 201 || e.Response.StatusText != "Created" ||
+		e.Response.Content.Text != `{"id": 1, "name": "doggie"}` || e.Response.Content.MimeType != "application/json" {
+		t.Errorf("unexpected response %d %s %v", e.Response.Status, e.Response.StatusText, e.Response.Content)
+	}
+
+	if strings.Contains(string(harBytes), "secret") || strings.Contains(string(harBytes), "session=") {
+		t.Errorf("secrets found in the recording:\n%s", string(harBytes))
+	}
+	if v, _ := nameValue(e.Request.QueryString, "api_key"); v != maskedValue {
+		t.Errorf("expected the api key masked in the query string, got %q", v)
+	}
+	if v, _ := nameValue(e.Request.QueryString, "status"); v != "sold" {
+		t.Errorf("expected the other query params kept, got %q", v)
+	}
+	if !strings.Contains(e.Request.URL, "status=sold") {
+		t.Errorf("unexpected url %s", e.Request.URL)
+	}
+	if v, _ := nameValue(e.Request.Headers, "Authorization"); v != maskedValue {
+		t.Errorf("expected the authorization header masked, got %q", v)
+	}
+	if v, _ := nameValue(e.Request.Headers, "X-Client-Key"); v != maskedValue {
+		t.Errorf("expected the api key header masked, got %q", v)
+	}
+	if v, _ := nameValue(e.Request.Headers, "X-Trace"); v != "abc" {
+		t.Errorf("expected the other headers kept, got %q", v)
+	}
+	if v, ok := nameValue(e.Request.Cookies, "session"); !ok || v != maskedValue {
+		t.Errorf("expected the request cookie masked, got %q", v)
+	}
+	if v, ok := nameValue(e.Response.Cookies, "session"); !ok || v != maskedValue {
+		t.Errorf("expected the response cookie masked, got %q", v)
+	}
+	if v, _ := nameValue(e.Response.Headers, "Set-Cookie"); v != maskedValue {
+		t.Errorf("expected the Set-Cookie header masked, got %q", v)
+	}
+}
+
+func TestHarRecorderShowSecrets(t *testing.T) {
+	_, har := recordExchange(t, true)
+	e := har.Log.Entries[0]
+	if v, _ := nameValue(e.Request.QueryString, "api_key"); v != "secret-key" || !strings.Contains(e.Request.URL, "api_key=secret-key") {
+		t.Errorf("expected the api key shown, got %q in %s", v, e.Request.URL)
+	}
+	if v, _ := nameValue(e.Request.Headers, "X-Client-Key"); v != "secret-client" {
+		t.Errorf("expected the api key header shown, got %q", v)
+	}
+	if v, _ := nameValue(e.Request.Cookies, "session"); v != "client-session" {
+		t.Errorf("expected the request cookie shown, got %q", v)
+	}
+	if v, _ := nameValue(e.Response.Cookies, "session"); v != "server-session" {
+		t.Errorf("expected the response cookie shown, got %q", v)
+	}
+}
@@ -0,0 +1,156 @@
+package mqplan
+
+import (
+	"strings"
+
+	"github.com/gbatanov/meqa/mqswag"
+)
+
+// Operations returns the operations the suite exercises, keyed by mqswag.OperationKey. The operations
+// of the suites it refers to are included.
+func (suite *TestSuite) Operations() map[string]bool {
+	ops := make(map[string]bool)
+	visited := make(map[*TestSuite]bool)
+	var collect func(s *TestSuite)
+	collect = func(s *TestSuite) {
+		if s == nil || visited[s] {
+			return
+		}
+		visited[s] = true
+		for _, t := range s.Tests {
+			if len(t.Ref) > 0 && s.plan != nil {
+				collect(s.plan.SuiteMap[t.Ref])
+			} else if len(t.Path) > 0 && len(t.Method) > 0 {
+				ops[mqswag.OperationKey(t.Method, t.Path)] = true
+			}
+		}
+	}
+	collect(suite)
+	return ops
+}
+
+// SuiteSelected checks whether the suite exercises any operation selected by the plan's filter.
+func (plan *TestPlan) SuiteSelected(suite *TestSuite) bool {
+	if plan.Filter.IsEmpty() {
+		return true
+	}
+	for op := range suite.Operations() {
+		fields := strings.SplitN(op, " ", 2)
+		if plan.Filter.MatchesPath(plan.swagger, fields[0], fields[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTests returns the suite with only the tests affected, the earlier tests they refer to through
+// {{test.section.param}} and the suite's leading meqa_init, or nil if none of its tests is affected.
+func (suite *TestSuite) filterTests(affected func(t *Test) bool) *TestSuite {
+	keep := make([]bool, len(suite.Tests))
+	for i, t := range suite.Tests {
+		keep[i] = affected(t)
+	}
+	// The references point at earlier tests, so going backward catches the references of the tests kept
+	// for being referred to as well.
+	for i := len(suite.Tests) - 1; i >= 0; i-- {
+		if !keep[i] {
+			continue
+		}
+		names := suite.Tests[i].references()
+		for j := 0; j < i; j++ {
+			if names[suite.Tests[j].Name] {
+				keep[j] = true
+			}
+		}
+	}
+	var tests []*Test
+	for i, t := range suite.Tests {
+		if keep[i] {
+			tests = append(tests, t)
+		}
+	}
+	if len(tests) == 0 {
+		return nil
+	}
+	// The suite's own meqa_init holds its settings, it goes with any test kept.
+	if first := suite.Tests[0]; first.Name == MeqaInit && !keep[0] {
+		tests = append([]*Test{first}, tests...)
+	}
+	filtered := *suite
+	filtered.Tests = tests
+	return &filtered
+}
+
+// FilterByOperations returns a plan with only the tests that exercise one of the operations, directly or
+// through the suite they refer to, together with the earlier tests of their suites they refer to through
+// {{test.section.param}}. The suites left without tests are dropped. The suites the tests kept refer to
+// are kept too, and so are the meqa_init settings.
+func (plan *TestPlan) FilterByOperations(operations map[string]bool) *TestPlan {
+	filtered := &TestPlan{}
+	filtered.Init(plan.swagger, plan.db)
+	filtered.comment = plan.comment
+	(&filtered.TestParams).Copy(&plan.TestParams)
+	filtered.Strict = plan.Strict
+
+	affected := func(t *Test) bool {
+		if len(t.Ref) > 0 {
+			for op := range plan.SuiteMap[t.Ref].Operations() {
+				if operations[op] {
+					return true
+				}
+			}
+			return false
+		}
+		return len(t.Path) > 0 && operations[mqswag.OperationKey(t.Method, t.Path)]
+	}
+	kept := make(map[string]*TestSuite)
+	for _, suite := range plan.SuiteList {
+		if suite.Name == MeqaInit {
+			continue
+		}
+		if s := suite.filterTests(affected); s != nil {
+			kept[suite.Name] = s
+		}
+	}
+	// A test kept for being referred to may refer to a suite none of whose tests is affected, that suite
+	// runs whole.
+	var addSuite func(name string)
+	addSuite = func(name string) {
+		suite := plan.SuiteMap[name]
+		if suite == nil || kept[name] != nil {
+			return
+		}
+		kept[name] = suite
+		for _, t := range suite.Tests {
+			if len(t.Ref) > 0 {
+				addSuite(t.Ref)
+			}
+		}
+	}
+	for _, suite := range plan.SuiteList {
+		if s := kept[suite.Name]; s != nil {
+			for _, t := range s.Tests {
+				if len(t.Ref) > 0 {
+					addSuite(t.Ref)
+				}
+			}
+		}
+	}
+	if len(kept) == 0 {
+		return filtered
+	}
+
+	// A loaded plan keeps its meqa_init out of the suites, put it back.
+	if _, ok := plan.SuiteMap[MeqaInit]; !ok && len(plan.initTests) > 0 {
+		initSuite := CreateTestSuite(MeqaInit, plan.initTests, filtered)
+		filtered.Add(initSuite)
+	}
+	for _, suite := range plan.SuiteList {
+		if s := kept[suite.Name]; s != nil {
+			filtered.Add(s)
+		} else if suite.Name == MeqaInit {
+			filtered.Add(suite)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,119 @@
+package mqplan
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+const impactPlan = `
+meqa_init:
+  - name: meqa_init
+    strict: true
+---
+pets:
+  - name: meqa_init
+    timeout: 5s
+  - name: create
+    path: /pet
+    method: post
+  - name: list
+    path: /pet/findByStatus
+    method: get
+  - name: read
+    path: /pet/{petId}
+    method: get
+    pathParams:
+      petId: "{{create.outputs.id}}"
+  - name: update
+    path: /pet
+    method: put
+    bodyParams:
+      id: "{{ read.outputs.id }}"
+---
+orders:
+  - name: order
+    ref: place order
+---
+place order:
+  - name: place
+    path: /store/order
+    method: post
+`
+
+func TestImpact(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := mqswag.NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	dag.Sort()
+
+	affected, unmatched := dag.AffectedOperations([]string{"Category", "nothing"})
+	ops := make(map[string]bool)
+	for _, n := range affected {
+		ops[mqswag.OperationKey(n.GetMethod(), n.GetName())] = true
+	}
+	// Category is part of Pet, and orders are placed for pets.
+	for _, key := range []string{"post /pet", "get /pet/{petId}", "post /store/order"} {
+		if !ops[key] {
+			t.Errorf("%s should be affected by a change of Category", key)
+		}
+	}
+	if len(unmatched) != 1 || unmatched[0] != "nothing" {
+		t.Errorf("expected nothing to be unmatched, got %v", unmatched)
+	}
+
+	planFile := filepath.Join(t.TempDir(), "plan.yml")
+	if err = os.WriteFile(planFile, []byte(impactPlan), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+	plan := &TestPlan{}
+	if err = plan.InitFromFile(planFile, db); err != nil {
+		t.Fatal(err)
+	}
+
+	filtered := plan.FilterByOperations(map[string]bool{"post /store/order": true})
+	var names []string
+	for _, suite := range filtered.SuiteList {
+		names = append(names, suite.Name)
+	}
+	// The orders suite refers to the place order suite.
+	if strings.Join(names, ",") != "meqa_init,orders,place order" {
+		t.Errorf("unexpected suites kept: %v", names)
+	}
+	if !filtered.SuiteMap[MeqaInit].Tests[0].Strict {
+		t.Errorf("the meqa_init settings should be kept")
+	}
+
+	// The tests affected are kept with the earlier tests they refer to and the suite's meqa_init, the others go.
+	filtered = plan.FilterByOperations(map[string]bool{"put /pet": true})
+	names = nil
+	for _, test := range filtered.SuiteMap["pets"].Tests {
+		names = append(names, test.Name)
+	}
+	if len(filtered.SuiteList) != 2 || strings.Join(names, ",") != "meqa_init,create,read,update" {
+		t.Errorf("expected update and the tests it refers to in %d suites, got %v", len(filtered.SuiteList), names)
+	}
+	if filtered.SuiteMap["pets"].Tests[0].Timeout != "5s" {
+		t.Errorf("the suite's meqa_init settings should be kept")
+	}
+	if len(plan.SuiteMap["pets"].Tests) != 5 {
+		t.Errorf("the plan filtered should be left as it is")
+	}
+
+	if len(plan.FilterByOperations(map[string]bool{"delete /pet/{petId}": true}).SuiteList) != 0 {
+		t.Errorf("no suite exercises delete /pet/{petId}")
+	}
+}
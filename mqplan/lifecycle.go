@@ -0,0 +1,282 @@
+package mqplan
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/go-openapi/spec"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// resource is a class of objects together with the operations that create, read, list, update and
+// delete them.
+type resource struct {
+	class   string
+	keyProp string            // The property that identifies the objects.
+	keyPath map[string]string // The path params that take the key, for the operations that have one.
+	create  *mqswag.DAGNode
+	read    *mqswag.DAGNode
+	list    *mqswag.DAGNode
+	update  *mqswag.DAGNode
+	delete  *mqswag.DAGNode
+}
+
+// operationMethod returns what the operation does, which is the method unless the tag says otherwise.
+func operationMethod(node *mqswag.DAGNode) string {
+	op, _ := node.Data.(*spec.Operation)
+	if op != nil {
+		if tag := mqswag.GetMeqaTag(op.Description); tag != nil && len(tag.Operation) > 0 {
+			return tag.Operation
+		}
+	}
+	return node.GetMethod()
+}
+
+// classifyOperation finds the class the operation works on, the tags first, then the schemas. It
+// returns the path param that takes the key of the object, if there is one, and whether the operation
+// returns an array of the objects.
+func classifyOperation(swagger *mqswag.Swagger, node *mqswag.DAGNode) (class string, keyParam string, keyProp string, isArray bool) {
+	op, _ := node.Data.(*spec.Operation)
+	if op == nil {
+		return
+	}
+	params := op.Parameters
+	if pathItem, ok := swagger.Paths.Paths[node.GetName()]; ok {
+		params = ParamsAdd(append([]spec.Parameter{}, op.Parameters...), pathItem.Parameters)
+	}
+	for _, p := range params {
+		if tag := mqswag.GetMeqaTag(p.Description); p.In == "path" && tag != nil && len(tag.Class) > 0 && len(tag.Property) > 0 {
+			class, keyParam, keyProp = tag.Class, p.Name, tag.Property
+		}
+	}
+	if tag := mqswag.GetMeqaTag(op.Description); tag != nil && len(tag.Class) > 0 {
+		class = tag.Class
+	}
+	var respClass string
+	var respArray bool
+	if _, resp := mqswag.SuccessResponse(op); resp != nil {
+		respClass, respArray = swagger.SchemaClass(resp.Schema, resp.Description)
+	}
+	if len(class) == 0 {
+		for _, p := range params {
+			if p.In == "body" {
+				class, _ = swagger.SchemaClass(p.Schema, p.Description)
+			}
+		}
+	}
+	if len(class) == 0 {
+		class = respClass
+	}
+	isArray = respArray && respClass == class
+	return
+}
+
+// findResources groups the operations by the class of the objects they work on.
+func findResources(swagger *mqswag.Swagger, dag *mqswag.DAG) []*resource {
+	resourceMap := make(map[string]*resource)
+	var resources []*resource
+	for _, node := range dag.TopologicalOrder() {
+		if node.GetType() != mqswag.TypeOp {
+			continue
+		}
+		class, keyParam, keyProp, isArray := classifyOperation(swagger, node)
+		if len(class) == 0 {
+			continue
+		}
+		r := resourceMap[class]
+		if r == nil {
+			r = &resource{class: class, keyPath: make(map[string]string)}
+			resourceMap[class] = r
+			resources = append(resources, r)
+		}
+		if len(keyParam) > 0 {
+			r.keyProp = keyProp
+			r.keyPath[node.Name] = keyParam
+		}
+		// The lightest operation of each kind goes first, and wins.
+		switch method := operationMethod(node); {
+		case method == mqswag.MethodPost && len(keyParam) == 0 && r.create == nil:
+			r.create = node
+		case method == mqswag.MethodGet && isArray && r.list == nil:
+			r.list = node
+		case method == mqswag.MethodGet && !isArray && len(keyParam) > 0 && r.read == nil:
+			r.read = node
+		case (method == mqswag.MethodPut || method == mqswag.MethodPatch) && (r.update == nil || method == mqswag.MethodPut &&
+			operationMethod(r.update) == mqswag.MethodPatch):
+			r.update = node
+		case method == mqswag.MethodDelete && len(keyParam) > 0 && r.delete == nil:
+			r.delete = node
+		}
+	}
+	for _, r := range resources {
+		if len(r.keyProp) == 0 {
+			if schema := swagger.FindSchemaByName(r.class); schema != nil {
+				if _, ok := schema.Properties["id"]; ok {
+					r.keyProp = "id"
+				}
+			}
+		}
+	}
+	return resources
+}
+
+// checkedProperty returns a property that the update always sends and the reads can check, i.e. a
+// required property of a simple type that is not the key.
+func (r *resource) checkedProperty(swagger *mqswag.Swagger) string {
+	schema := swagger.FindSchemaByName(r.class)
+	if schema == nil {
+		return ""
+	}
+	required := append([]string{}, schema.Required...)
+	sort.Strings(required)
+	for _, name := range required {
+		prop, ok := schema.Properties[name]
+		if !ok || name == r.keyProp || len(prop.Ref.String()) > 0 {
+			continue
+		}
+		for _, t := range []string{gojsonschema.TYPE_STRING, gojsonschema.TYPE_INTEGER, gojsonschema.TYPE_NUMBER, gojsonschema.TYPE_BOOLEAN} {
+			if prop.Type.Contains(t) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// keyed sets the key of the object on the test, taking the value from the source.
+func (r *resource) keyed(t *Test, node *mqswag.DAGNode, source string) *Test {
+	if param, ok := r.keyPath[node.Name]; ok {
+		t.PathParams = map[string]interface{}{param: source}
+	} else if len(r.keyProp) > 0 {
+		t.BodyParams = map[string]interface{}{r.keyProp: source}
+	}
+	return t
+}
+
+// queried sets the query params of the list so that the object created matches them: the params named
+// after a property of the class take the value created, and the other optional params are left out so
+// that they don't filter the object out or page it away.
+func (r *resource) queried(swagger *mqswag.Swagger, t *Test, node *mqswag.DAGNode, create *Test) *Test {
+	op, _ := node.Data.(*spec.Operation)
+	if op == nil {
+		return t
+	}
+	params := op.Parameters
+	if pathItem, ok := swagger.Paths.Paths[node.GetName()]; ok {
+		params = ParamsAdd(append([]spec.Parameter{}, op.Parameters...), pathItem.Parameters)
+	}
+	var props map[string]spec.Schema
+	if schema := swagger.FindSchemaByName(r.class); schema != nil {
+		props = schema.Properties
+	}
+	for _, p := range params {
+		if p.In != "query" {
+			continue
+		}
+		prop := p.Name
+		if tag := mqswag.GetMeqaTag(p.Description); tag != nil && tag.Class == r.class && len(tag.Property) > 0 {
+			prop = tag.Property
+		}
+		if t.QueryParams == nil {
+			t.QueryParams = make(map[string]interface{})
+		}
+		if _, ok := props[prop]; ok {
+			t.QueryParams[p.Name] = fmt.Sprintf("{{%s.outputs.%s}}", create.Name, prop)
+		} else if !p.Required {
+			t.QueryParams[p.Name] = nil
+		}
+	}
+	return t
+}
+
+func notFoundStatus(node *mqswag.DAGNode) interface{} {
+	op, _ := node.Data.(*spec.Operation)
+	if op != nil && op.Responses != nil {
+		if _, ok := op.Responses.StatusCodeResponses[http.StatusNotFound]; ok {
+			return http.StatusNotFound
+		}
+	}
+	return "fail"
+}
+
+// lifecycleSuite generates create -> read -> list -> update -> read -> delete -> read for the resource,
+// skipping the operations the resource doesn't have.
+func (r *resource) lifecycleSuite(swagger *mqswag.Swagger, plan *TestPlan) *TestSuite {
+	testSuite := CreateTestSuite(fmt.Sprintf("%s -- lifecycle", r.class), nil, plan)
+	testId := 0
+	add := func(node *mqswag.DAGNode) *Test {
+		testId++
+		t := CreateTestFromOp(node, testId)
+		testSuite.Tests = append(testSuite.Tests, t)
+		return t
+	}
+	output := func(t *Test, prop string) string {
+		return fmt.Sprintf("{{%s.outputs.%s}}", t.Name, prop)
+	}
+
+	create := add(r.create)
+	if len(r.keyProp) == 0 {
+		testSuite.comment = fmt.Sprintf("No property identifies the %s objects, the tests can't refer to the one created", r.class)
+		return testSuite
+	}
+	key := output(create, r.keyProp)
+	if r.read != nil {
+		read := r.keyed(add(r.read), r.read, key)
+		read.Expect = map[string]interface{}{ExpectBody: map[string]interface{}{r.keyProp: key}}
+	}
+	if r.list != nil {
+		list := r.queried(swagger, add(r.list), r.list, create)
+		list.Expect = map[string]interface{}{ExpectContains: []interface{}{map[string]interface{}{r.keyProp: key}}}
+	}
+	if r.update != nil {
+		update := r.keyed(add(r.update), r.update, key)
+		if r.read != nil {
+			read := r.keyed(add(r.read), r.read, key)
+			body := map[string]interface{}{r.keyProp: key}
+			if prop := r.checkedProperty(swagger); len(prop) > 0 {
+				body[prop] = fmt.Sprintf("{{%s.bodyParams.%s}}", update.Name, prop)
+			}
+			read.Expect = map[string]interface{}{ExpectBody: body}
+		}
+	}
+	if r.delete != nil {
+		r.keyed(add(r.delete), r.delete, key)
+		if r.read != nil {
+			read := r.keyed(add(r.read), r.read, key)
+			read.Expect = map[string]interface{}{ExpectStatus: notFoundStatus(r.read)}
+		}
+	}
+	return testSuite
+}
+
+// GenerateLifecycleTestPlan generates a test suite for every class of objects that can be created. The suite
+// walks one object through its whole life: create, read, list, update, read the update back, delete, and
+// read again expecting it to be gone. The meqa tags tell which operations work on the class. The classes
+// none of whose operations are selected by the filter are left out.
+func GenerateLifecycleTestPlan(swagger *mqswag.Swagger, dag *mqswag.DAG, filter *mqswag.OperationFilter) (*TestPlan, error) {
+	testPlan := &TestPlan{}
+	testPlan.Init(swagger, nil)
+	testPlan.comment = `
+In this test plan, every test suite follows one object through its lifecycle: create, read, list,
+update, read the update back, delete, then read again expecting it to be gone.
+`
+	addInitTestSuite(testPlan)
+
+	for _, r := range findResources(swagger, dag) {
+		if r.create == nil {
+			continue
+		}
+		selected := false
+		for _, node := range []*mqswag.DAGNode{r.create, r.read, r.list, r.update, r.delete} {
+			if node != nil && filter.MatchesNode(node) {
+				selected = true
+			}
+		}
+		if selected {
+			testPlan.Add(r.lifecycleSuite(swagger, testPlan))
+		}
+	}
+	return testPlan, nil
+}
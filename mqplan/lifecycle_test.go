@@ -0,0 +1,115 @@
+package mqplan
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func TestLifecycleTestPlan(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := mqswag.NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	dag.Sort()
+
+	plan, err := GenerateLifecycleTestPlan(swagger, dag, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite := plan.SuiteMap["Pet -- lifecycle"]
+	if suite == nil || plan.SuiteMap["Order -- lifecycle"] == nil {
+		t.Fatalf("expected the Pet and Order lifecycles, got %d suites", len(plan.SuiteList))
+	}
+	expected := []string{"post /pet", "get /pet/{petId}", "get /pet/findByStatus", "put /pet", "get /pet/{petId}",
+		"delete /pet/{petId}", "get /pet/{petId}"}
+	if len(suite.Tests) != len(expected) {
+		t.Fatalf("expected %d tests, got %d", len(expected), len(suite.Tests))
+	}
+	for i, test := range suite.Tests {
+		if op := mqswag.OperationKey(test.Method, test.Path); op != expected[i] {
+			t.Errorf("test %d: expected %s, got %s", i+1, expected[i], op)
+		}
+	}
+	key := "{{post_addPet_1.outputs.id}}"
+	if suite.Tests[1].PathParams["petId"] != key || suite.Tests[3].BodyParams.(map[string]interface{})["id"] != key {
+		t.Errorf("the tests should refer to the pet created")
+	}
+	contains, _ := suite.Tests[2].Expect[ExpectContains].([]interface{})
+	if len(contains) != 1 || contains[0].(map[string]interface{})["id"] != key {
+		t.Errorf("the list should contain the pet created, got %v", suite.Tests[2].Expect)
+	}
+	if status := suite.Tests[2].QueryParams["status"]; status != "{{post_addPet_1.outputs.status}}" {
+		t.Errorf("the list should query the status of the pet created, got %v", status)
+	}
+	if body := suite.Tests[4].Expect[ExpectBody].(map[string]interface{}); body["name"] != "{{put_updatePet_4.bodyParams.name}}" {
+		t.Errorf("the read after the update should check the name, got %v", body)
+	}
+	if status := suite.Tests[6].Expect[ExpectStatus]; status != http.StatusNotFound {
+		t.Errorf("the read after the delete should expect 404, got %v", status)
+	}
+
+	filter := &mqswag.OperationFilter{Paths: mqswag.StringList{"/store/**"}}
+	plan, _ = GenerateLifecycleTestPlan(swagger, dag, filter)
+	if len(plan.SuiteList) != 2 || plan.SuiteMap["Order -- lifecycle"] == nil {
+		t.Errorf("expected only the Order lifecycle, got %d suites", len(plan.SuiteList))
+	}
+}
+
+const containsPlan = `
+pet list:
+  - name: create
+    path: /pet
+    method: post
+  - name: list
+    path: /pet/findByStatus
+    method: get
+    queryParams:
+      status: "{{create.outputs.status}}"
+    expect:
+      contains:
+        - id: "{{create.outputs.id}}"
+`
+
+func runContains(t *testing.T, listBody string) map[string]int {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+
+	har := &Har{}
+	har.Log.Entries = []*HarEntry{
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie", "status": "sold"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/findByStatus", "/pet/findByStatus", 200, listBody)}
+	defer useTransport(NewReplayer(har))()
+
+	plan := &TestPlan{}
+	plan.Init(swagger, db)
+	if err = plan.AddFromString(containsPlan); err != nil {
+		t.Fatal(err)
+	}
+	counts, _ := plan.Run("pet list", nil)
+	return counts
+}
+
+func TestExpectContains(t *testing.T) {
+	counts := runContains(t, `[{"id": 3, "name": "cat", "status": "sold"}, {"id": 7, "name": "doggie", "status": "sold"}]`)
+	if counts[mqutil.Passed] != 2 || counts[mqutil.Failed] != 0 {
+		t.Errorf("expected the list to contain the pet created, got %v", counts)
+	}
+	counts = runContains(t, `[{"id": 3, "name": "cat", "status": "sold"}]`)
+	if counts[mqutil.Passed] != 1 || counts[mqutil.Failed] != 1 {
+		t.Errorf("expected the list without the pet created to fail, got %v", counts)
+	}
+}
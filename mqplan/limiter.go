@@ -0,0 +1,75 @@
+package mqplan
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+// Limiter caps the rate of the requests and the requests in flight, for the servers behind a rate
+// limiting gateway. It's shared by all the suites of the plan and is safe for concurrent use.
+type Limiter struct {
+	interval time.Duration // The time between the starts of two requests, 0 for no rate limit.
+	inFlight chan struct{} // Holds a token per request in flight, nil for no cap.
+
+	mutex sync.Mutex
+	next  time.Time // When the next request may start.
+}
+
+// NewLimiter creates the limiter for the requests per second and the requests in flight, 0 for no limit.
+// Returns nil if neither is limited.
+func NewLimiter(rps float64, maxInFlight int) (*Limiter, error) {
+	if rps < 0 {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid requests per second: %v", rps))
+	}
+	if maxInFlight < 0 {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid max requests in flight: %d", maxInFlight))
+	}
+	if rps == 0 && maxInFlight == 0 {
+		return nil, nil
+	}
+	l := &Limiter{}
+	if rps > 0 {
+		l.interval = time.Duration(float64(time.Second) / rps)
+	}
+	if maxInFlight > 0 {
+		l.inFlight = make(chan struct{}, maxInFlight)
+	}
+	return l, nil
+}
+
+// Acquire blocks until a request may be sent. Every Acquire must be followed by a Release once the
+// response is received. A nil limiter doesn't limit anything.
+func (l *Limiter) Acquire() {
+	if l == nil {
+		return
+	}
+	if l.inFlight != nil {
+		l.inFlight <- struct{}{}
+	}
+	if l.interval == 0 {
+		return
+	}
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mutex.Unlock()
+	if wait > 0 {
+		mqutil.Logger.Printf("rate limited, waiting %v", wait)
+		time.Sleep(wait)
+	}
+}
+
+// Release tells the limiter the request acquired is done.
+func (l *Limiter) Release() {
+	if l == nil || l.inFlight == nil {
+		return
+	}
+	<-l.inFlight
+}
@@ -0,0 +1,100 @@
+package mqplan
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func TestLimiterRate(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	l, err := NewLimiter(100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Acquire()
+			l.Release()
+		}()
+	}
+	wg.Wait()
+	// The first request goes right away, the other 5 are 10ms apart.
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected 6 requests at 100 rps to take at least 50ms, took %v", elapsed)
+	}
+}
+
+func TestLimiterInFlight(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	l, err := NewLimiter(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Acquire()
+			mutex.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mutex.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mutex.Lock()
+			inFlight--
+			mutex.Unlock()
+			l.Release()
+		}()
+	}
+	wg.Wait()
+	if maxInFlight != 2 {
+		t.Errorf("expected at most 2 requests in flight, got %d", maxInFlight)
+	}
+}
+
+func TestNewLimiter(t *testing.T) {
+	if l, err := NewLimiter(0, 0); l != nil || err != nil {
+		t.Errorf("expected no limiter without limits, got %v %v", l, err)
+	}
+	if _, err := NewLimiter(-1, 0); err == nil {
+		t.Errorf("expected an error for a negative rate")
+	}
+	if _, err := NewLimiter(0, -1); err == nil {
+		t.Errorf("expected an error for a negative cap")
+	}
+	// A nil limiter lets everything through.
+	var l *Limiter
+	l.Acquire()
+	l.Release()
+}
+
+func TestLimitsFromPlan(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	plan := &TestPlan{}
+	plan.Init(nil, nil)
+	err := plan.AddFromString(`
+meqa_init:
+  - name: meqa_init
+    rps: 2.5
+    maxInFlight: 4
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.RPS != 2.5 || plan.MaxInFlight != 4 {
+		t.Errorf("expected the limits of the meqa_init, got %v rps and %d in flight", plan.RPS, plan.MaxInFlight)
+	}
+}
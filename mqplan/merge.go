@@ -0,0 +1,257 @@
+package mqplan
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"gopkg.in/yaml.v3"
+)
+
+// MergeReport lists what happened to the tests when merging a generated test plan into an existing one.
+// The tests are named "suite: test".
+type MergeReport struct {
+	Added    []string // The new tests, usually for the new operations.
+	Updated  []string // The tests not touched by hand, replaced by the generated ones.
+	Kept     []string // The tests edited or added by hand, left as they are.
+	Obsolete []string // The tests of the operations no longer in the spec.
+}
+
+// testKeys returns the keys that identify the tests in a suite across the generations. The generated
+// test names are numbered across the whole plan, so the numbers shift when operations are added. The
+// tests are identified by their operation and their order among the tests of the same operation instead.
+func testKeys(tests []*Test) []string {
+	counts := make(map[string]int)
+	var keys []string
+	for _, t := range tests {
+		var key string
+		if len(t.Ref) > 0 {
+			key = "ref " + t.Ref
+		} else if len(t.Path) > 0 {
+			key = mqswag.OperationKey(t.Method, t.Path)
+		} else {
+			key = "name " + t.Name
+		}
+		counts[key]++
+		keys = append(keys, fmt.Sprintf("%s #%d", key, counts[key]))
+	}
+	return keys
+}
+
+func testMap(suite *TestSuite) map[string]*Test {
+	tests := make(map[string]*Test)
+	if suite == nil {
+		return tests
+	}
+	for i, key := range testKeys(suite.Tests) {
+		tests[key] = suite.Tests[i]
+	}
+	return tests
+}
+
+// The test name in the {{test.section.param}} references.
+var referenceRegexp = regexp.MustCompile(`\{\{\s*([^.\s}]+)\.`)
+
+// mapStrings replaces the strings in the value, however deep in the maps and arrays, with what f returns.
+func mapStrings(v interface{}, f func(string) string) interface{} {
+	switch value := v.(type) {
+	case string:
+		return f(value)
+	case map[string]interface{}:
+		for k, e := range value {
+			value[k] = mapStrings(e, f)
+		}
+	case []interface{}:
+		for i, e := range value {
+			value[i] = mapStrings(e, f)
+		}
+	}
+	return v
+}
+
+// mapStrings replaces the strings in the test's parameters and expectations with what f returns.
+func (t *Test) mapStrings(f func(string) string) {
+	for _, params := range []map[string]interface{}{t.PathParams, t.QueryParams, t.FormParams, t.HeaderParams, t.Expect} {
+		mapStrings(params, f)
+	}
+	t.BodyParams = mapStrings(t.BodyParams, f)
+	if t.WaitUntil != nil {
+		t.WaitUntil.Body = mapStrings(t.WaitUntil.Body, f)
+	}
+}
+
+// renameReferences points the references of the test at the renamed tests.
+func (t *Test) renameReferences(renames map[string]string) {
+	t.mapStrings(func(str string) string {
+		return referenceRegexp.ReplaceAllStringFunc(str, func(ref string) string {
+			name := referenceRegexp.FindStringSubmatch(ref)[1]
+			if newName, ok := renames[name]; ok {
+				return strings.Replace(ref, name, newName, 1)
+			}
+			return ref
+		})
+	})
+}
+
+// references returns the names of the tests the test refers to.
+func (t *Test) references() map[string]bool {
+	names := make(map[string]bool)
+	t.mapStrings(func(str string) string {
+		for _, match := range referenceRegexp.FindAllStringSubmatch(str, -1) {
+			names[match[1]] = true
+		}
+		return str
+	})
+	return names
+}
+
+// renameTests gives the generated tests the names of the existing tests they match, and the new tests
+// names no existing test has, so that the references the existing tests have keep working.
+func renameTests(ours *TestSuite, theirs *TestSuite) {
+	ourTests := testMap(ours)
+	used := make(map[string]bool)
+	for _, t := range ours.Tests {
+		used[t.Name] = true
+	}
+	renames := make(map[string]string)
+	for i, key := range testKeys(theirs.Tests) {
+		t := theirs.Tests[i]
+		name := t.Name
+		if ourTest := ourTests[key]; ourTest != nil {
+			name = ourTest.Name
+		} else {
+			for n := 2; used[name]; n++ {
+				name = fmt.Sprintf("%s_%d", t.Name, n)
+			}
+			used[name] = true
+		}
+		if name != t.Name {
+			renames[t.Name] = name
+		}
+	}
+	for _, t := range theirs.Tests {
+		if newName, ok := renames[t.Name]; ok {
+			t.Name = newName
+		}
+		t.renameReferences(renames)
+	}
+}
+
+func testEquals(a *Test, b *Test) bool {
+	aBytes, aErr := yaml.Marshal(a)
+	bBytes, bErr := yaml.Marshal(b)
+	return aErr == nil && bErr == nil && string(aBytes) == string(bBytes)
+}
+
+func operationExists(swagger *mqswag.Swagger, method string, path string) bool {
+	if swagger == nil || swagger.Paths == nil {
+		return true
+	}
+	pathItem, ok := swagger.Paths.Paths[path]
+	return ok && GetOperationByMethod(&pathItem, method) != nil
+}
+
+// MergePlans merges the newly generated test plan into the existing one, which may have been edited by
+// hand. The base is the plan generated last time, the one the existing plan started from, and can be nil.
+// A test is taken as edited by hand if it's different from the one in the base, or if there is no base.
+// The edited tests are kept, the others are replaced by the generated ones. The generated tests that are
+// not in the existing plan are added, unless they were in the base, meaning they were deleted by hand.
+// The tests of the operations no longer in the spec are marked obsolete. The meqa_init values of the
+// existing plan are kept. The generated tests are renamed after the existing tests they match, in the
+// generated plan too, so that it can be kept as the base of the next merge.
+func MergePlans(existing *TestPlan, base *TestPlan, generated *TestPlan) (*TestPlan, *MergeReport) {
+	merged := &TestPlan{}
+	merged.Init(generated.swagger, generated.db)
+	merged.comment = generated.comment
+	report := &MergeReport{}
+
+	baseSuites := make(map[string]*TestSuite)
+	if base != nil {
+		baseSuites = base.SuiteMap
+	}
+
+	initSuite := generated.SuiteMap[MeqaInit]
+	if len(existing.initTests) > 0 {
+		comment := ""
+		if initSuite != nil {
+			comment = initSuite.comment
+		}
+		initSuite = CreateTestSuite(MeqaInit, existing.initTests, merged)
+		initSuite.comment = comment
+	}
+	if initSuite != nil {
+		merged.Add(initSuite)
+	}
+
+	for _, suite := range existing.SuiteList {
+		if suite.Name == MeqaInit {
+			continue
+		}
+		merged.Add(mergeSuite(suite, baseSuites[suite.Name], generated.SuiteMap[suite.Name], merged, report))
+	}
+	for _, suite := range generated.SuiteList {
+		_, inExisting := existing.SuiteMap[suite.Name]
+		_, inBase := baseSuites[suite.Name]
+		if suite.Name == MeqaInit || inExisting || (base != nil && inBase) {
+			continue
+		}
+		for _, t := range suite.Tests {
+			report.Added = append(report.Added, suite.Name+": "+t.Name)
+		}
+		merged.Add(suite)
+	}
+	return merged, report
+}
+
+func mergeSuite(ours *TestSuite, base *TestSuite, theirs *TestSuite, merged *TestPlan, report *MergeReport) *TestSuite {
+	if theirs != nil {
+		renameTests(ours, theirs)
+	}
+	baseTests := testMap(base)
+	theirTests := testMap(theirs)
+	ourKeys := testKeys(ours.Tests)
+
+	var tests []*Test
+	for i, t := range ours.Tests {
+		key := ourKeys[i]
+		name := ours.Name + ": " + t.Name
+		baseTest := baseTests[key]
+		switch {
+		case len(t.Path) > 0 && !operationExists(merged.swagger, t.Method, t.Path):
+			t.Obsolete = true
+			tests = append(tests, t)
+			report.Obsolete = append(report.Obsolete, name)
+		case baseTest != nil && testEquals(baseTest, t) && theirTests[key] != nil:
+			tests = append(tests, theirTests[key])
+			report.Updated = append(report.Updated, name)
+		default:
+			// The operation may be back in the spec.
+			t.Obsolete = false
+			tests = append(tests, t)
+			if baseTest == nil || !testEquals(baseTest, t) {
+				report.Kept = append(report.Kept, name)
+			}
+		}
+	}
+
+	ourTests := testMap(ours)
+	if theirs != nil {
+		for i, key := range testKeys(theirs.Tests) {
+			if _, inOurs := ourTests[key]; inOurs {
+				continue
+			}
+			if _, inBase := baseTests[key]; inBase {
+				continue
+			}
+			tests = append(tests, theirs.Tests[i])
+			report.Added = append(report.Added, ours.Name+": "+theirs.Tests[i].Name)
+		}
+	}
+
+	suite := CreateTestSuite(ours.Name, tests, merged)
+	if theirs != nil {
+		suite.comment = theirs.comment
+	}
+	return suite
+}
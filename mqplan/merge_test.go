@@ -0,0 +1,213 @@
+package mqplan
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+const mergeBase = `
+pets:
+  - name: post_addPet_1
+    path: /pet
+    method: post
+  - name: get_getPetById_2
+    path: /pet/{petId}
+    method: get
+  - name: get_gone_3
+    path: /pet/gone
+    method: get
+`
+
+const mergeExisting = `
+meqa_init:
+  - name: meqa_init
+    strict: true
+---
+pets:
+  - name: post_addPet_1
+    path: /pet
+    method: post
+  - name: get_getPetById_2
+    path: /pet/{petId}
+    method: get
+    expect:
+      status: 200
+  - name: get_gone_3
+    path: /pet/gone
+    method: get
+---
+mine:
+  - name: order
+    path: /store/order
+    method: post
+`
+
+const mergeGenerated = `
+pets:
+  - name: post_addPet_1
+    path: /pet
+    method: post
+    bodyParams:
+      name: generated
+  - name: get_getPetById_2
+    path: /pet/{petId}
+    method: get
+  - name: put_updatePet_3
+    path: /pet
+    method: put
+---
+orders:
+  - name: post_placeOrder_4
+    path: /store/order
+    method: post
+`
+
+func mergeLoader(t *testing.T) func(data string) *TestPlan {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+	return func(data string) *TestPlan {
+		plan := &TestPlan{}
+		plan.Init(swagger, db)
+		for _, chunk := range strings.Split(data, "---") {
+			if err := plan.AddFromString(chunk); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return plan
+	}
+}
+
+func TestMergePlans(t *testing.T) {
+	load := mergeLoader(t)
+	merged, report := MergePlans(load(mergeExisting), load(mergeBase), load(mergeGenerated))
+
+	if len(merged.SuiteList) != 4 || merged.SuiteList[0].Name != MeqaInit || !merged.SuiteList[0].Tests[0].Strict {
+		t.Fatalf("expected meqa_init with the existing values, pets, mine and orders, got %d suites", len(merged.SuiteList))
+	}
+	pets := merged.SuiteMap["pets"].Tests
+	if len(pets) != 4 {
+		t.Fatalf("expected 4 tests in pets, got %d", len(pets))
+	}
+	if pets[0].BodyParams == nil {
+		t.Errorf("the test not edited by hand should be regenerated")
+	}
+	if pets[1].Expect["status"] != 200 {
+		t.Errorf("the test edited by hand should be kept")
+	}
+	if !pets[2].Obsolete {
+		t.Errorf("the test of the removed operation should be obsolete")
+	}
+	if pets[3].Name != "put_updatePet_3" {
+		t.Errorf("the test of the new operation should be added")
+	}
+	if len(report.Added) != 2 || len(report.Updated) != 1 || len(report.Kept) != 2 || len(report.Obsolete) != 1 {
+		t.Errorf("unexpected report %v", report)
+	}
+}
+
+const renameBase = `
+pets:
+  - name: post_addPet_1
+    path: /pet
+    method: post
+  - name: get_getPetById_2
+    path: /pet/{petId}
+    method: get
+    pathParams:
+      petId: "{{post_addPet_1.outputs.id}}"
+`
+
+const renameExisting = `
+pets:
+  - name: post_addPet_1
+    path: /pet
+    method: post
+  - name: get_getPetById_2
+    path: /pet/{petId}
+    method: get
+    pathParams:
+      petId: "{{post_addPet_1.outputs.id}}"
+  - name: mine
+    path: /pet/{petId}
+    method: delete
+    pathParams:
+      petId: "{{post_addPet_1.outputs.id}}"
+`
+
+// The new operation shifts the numbers of the generated tests.
+const renameGenerated = `
+pets:
+  - name: post_uploadFile_1
+    path: /pet/{petId}/uploadImage
+    method: post
+  - name: post_addPet_2
+    path: /pet
+    method: post
+    bodyParams:
+      name: generated
+  - name: get_getPetById_3
+    path: /pet/{petId}
+    method: get
+    pathParams:
+      petId: "{{post_addPet_2.outputs.id}}"
+    expect:
+      body:
+        id: "{{ post_addPet_2.outputs.id }}"
+`
+
+func TestMergeKeepsNames(t *testing.T) {
+	load := mergeLoader(t)
+	generated := load(renameGenerated)
+	merged, report := MergePlans(load(renameExisting), load(renameBase), generated)
+
+	pets := merged.SuiteMap["pets"].Tests
+	if len(pets) != 4 || len(report.Updated) != 2 || len(report.Added) != 1 {
+		t.Fatalf("expected 2 tests updated and 1 added, got %d tests and %v", len(pets), report)
+	}
+	if pets[0].Name != "post_addPet_1" || pets[0].BodyParams == nil || pets[1].Name != "get_getPetById_2" {
+		t.Errorf("the updated tests should keep their names, got %s and %s", pets[0].Name, pets[1].Name)
+	}
+	if pets[1].PathParams["petId"] != "{{post_addPet_1.outputs.id}}" {
+		t.Errorf("the references of the updated tests should follow the names, got %v", pets[1].PathParams["petId"])
+	}
+	if body := pets[1].Expect[ExpectBody].(map[string]interface{}); body["id"] != "{{ post_addPet_1.outputs.id }}" {
+		t.Errorf("the references in the expectations should follow the names, got %v", body["id"])
+	}
+	if pets[2].Name != "mine" || pets[3].Name != "post_uploadFile_1" {
+		t.Errorf("expected the test kept then the one added, got %s and %s", pets[2].Name, pets[3].Name)
+	}
+
+	// The generated plan, kept as the base of the next merge, has the same names.
+	if tests := generated.SuiteMap["pets"].Tests; tests[1].Name != "post_addPet_1" || tests[2].Name != "get_getPetById_2" {
+		t.Errorf("the generated plan should be renamed too, got %s and %s", tests[1].Name, tests[2].Name)
+	}
+}
+
+func TestMergeNewNamesUnique(t *testing.T) {
+	load := mergeLoader(t)
+	existing := load(`
+pets:
+  - name: post_addPet_1
+    path: /store/order
+    method: post
+`)
+	merged, _ := MergePlans(existing, nil, load(`
+pets:
+  - name: post_addPet_1
+    path: /pet
+    method: post
+`))
+	pets := merged.SuiteMap["pets"].Tests
+	if len(pets) != 2 || pets[1].Name != "post_addPet_1_2" {
+		t.Errorf("expected the test added to get a name of its own, got %v", pets)
+	}
+}
@@ -0,0 +1,238 @@
+package mqplan
+
+import (
+	"fmt"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/go-openapi/spec"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const DefaultPairwiseCap = 20
+
+// generatedValue stands for a parameter left to the test runner to generate.
+type generatedValue struct{}
+
+// absentValue stands for a parameter left out of the request, which the test lists under absent.
+type absentValue struct{}
+
+// pairwiseFactor is a parameter and the values it takes in the combinations.
+type pairwiseFactor struct {
+	param  spec.Parameter
+	values []interface{}
+}
+
+// parameterFactor returns the values worth combining for the parameter: the enum values, true and false,
+// and absent for the optional ones. The parameters that are just present or absent are generated when
+// present. The body and the required parameters without interesting values are left out.
+func parameterFactor(p spec.Parameter) *pairwiseFactor {
+	if p.In == "body" {
+		return nil
+	}
+	var values []interface{}
+	switch {
+	case len(p.Enum) > 0:
+		values = append(values, p.Enum...)
+	case p.Type == gojsonschema.TYPE_ARRAY && p.Items != nil && len(p.Items.Enum) > 0:
+		for _, e := range p.Items.Enum {
+			values = append(values, []interface{}{e})
+		}
+	case p.Type == gojsonschema.TYPE_BOOLEAN:
+		values = []interface{}{true, false}
+	case !p.Required:
+		values = []interface{}{generatedValue{}}
+	default:
+		return nil
+	}
+	if !p.Required {
+		values = append(values, absentValue{})
+	}
+	return &pairwiseFactor{p, values}
+}
+
+// allPairs returns rows of value indexes, one per factor, such that every pair of values of any two
+// factors shows up in at least one row. The rows are built greedily: each starts with the first pair not
+// covered yet and picks the values covering the most new pairs for the other factors. At most max rows are
+// returned, 0 meaning no limit.
+func allPairs(sizes []int, max int) [][]int {
+	if len(sizes) == 0 {
+		return [][]int{{}}
+	}
+	if len(sizes) == 1 {
+		var rows [][]int
+		for v := 0; v < sizes[0] && (max <= 0 || v < max); v++ {
+			rows = append(rows, []int{v})
+		}
+		return rows
+	}
+
+	// covered[i][j][a*sizes[j]+b] is whether value a of factor i has been paired with value b of factor j.
+	covered := make([][][]bool, len(sizes))
+	uncovered := 0
+	for i := range sizes {
+		covered[i] = make([][]bool, len(sizes))
+		for j := i + 1; j < len(sizes); j++ {
+			covered[i][j] = make([]bool, sizes[i]*sizes[j])
+			uncovered += sizes[i] * sizes[j]
+		}
+	}
+	isCovered := func(i, a, j, b int) bool {
+		if i > j {
+			i, a, j, b = j, b, i, a
+		}
+		return covered[i][j][a*sizes[j]+b]
+	}
+
+	var rows [][]int
+	for uncovered > 0 && (max <= 0 || len(rows) < max) {
+		row := make([]int, len(sizes))
+		for i := range row {
+			row[i] = -1
+		}
+	seed:
+		for i := range sizes {
+			for j := i + 1; j < len(sizes); j++ {
+				for k, c := range covered[i][j] {
+					if !c {
+						row[i], row[j] = k/sizes[j], k%sizes[j]
+						break seed
+					}
+				}
+			}
+		}
+		for k := range sizes {
+			if row[k] >= 0 {
+				continue
+			}
+			best, bestCount := 0, -1
+			for v := 0; v < sizes[k]; v++ {
+				count := 0
+				for m := range sizes {
+					if row[m] >= 0 && !isCovered(k, v, m, row[m]) {
+						count++
+					}
+				}
+				if count > bestCount {
+					best, bestCount = v, count
+				}
+			}
+			row[k] = best
+		}
+		for i := range sizes {
+			for j := i + 1; j < len(sizes); j++ {
+				if k := row[i]*sizes[j] + row[j]; !covered[i][j][k] {
+					covered[i][j][k] = true
+					uncovered--
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// setParam sets the value of the parameter on the test, nothing for the generated ones.
+func setParam(t *Test, p spec.Parameter, value interface{}) {
+	switch value.(type) {
+	case generatedValue:
+		return
+	case absentValue:
+		t.Absent = append(t.Absent, p.Name)
+		return
+	}
+	var params *map[string]interface{}
+	switch p.In {
+	case "path":
+		params = &t.PathParams
+	case "query":
+		params = &t.QueryParams
+	case "header":
+		params = &t.HeaderParams
+	case "formData":
+		params = &t.FormParams
+	default:
+		return
+	}
+	if *params == nil {
+		*params = make(map[string]interface{})
+	}
+	(*params)[p.Name] = value
+}
+
+// GeneratePairwiseTestPlan generates a test suite for every operation, with the tests covering all the pairs
+// of the values of its parameters: the enum values, true and false, and present or absent for the optional
+// ones. Each suite first calls the operations creating the objects the operation uses. The deletes get the
+// objects created again before every test. There are at most max tests per operation, 0 meaning no limit.
+func GeneratePairwiseTestPlan(swagger *mqswag.Swagger, dag *mqswag.DAG, filter *mqswag.OperationFilter, max int) (*TestPlan, error) {
+	testPlan := &TestPlan{}
+	testPlan.Init(swagger, nil)
+	testPlan.comment = `
+In this test plan, every test suite calls one operation with the combinations of its parameters
+that cover every pair of values of any two parameters. The parameters under absent are left out.
+`
+	addInitTestSuite(testPlan)
+
+	producers := make(map[*mqswag.DAGNode]*mqswag.DAGNode)
+	var operations mqswag.NodeList
+	dag.IterateByWeight(func(previous *mqswag.DAGNode, current *mqswag.DAGNode) error {
+		if current.GetType() != mqswag.TypeOp {
+			return nil
+		}
+		for _, c := range current.Children {
+			if _, ok := producers[c]; c.GetType() == mqswag.TypeDef && !ok {
+				producers[c] = current
+			}
+		}
+		if filter.MatchesNode(current) {
+			operations = append(operations, current)
+		}
+		return nil
+	})
+
+	for _, node := range operations {
+		op := node.Data.(*spec.Operation)
+		params := op.Parameters
+		if pathItem, ok := swagger.Paths.Paths[node.GetName()]; ok {
+			params = ParamsAdd(append([]spec.Parameter{}, op.Parameters...), pathItem.Parameters)
+		}
+		var factors []*pairwiseFactor
+		var sizes []int
+		for _, p := range params {
+			if f := parameterFactor(p); f != nil {
+				factors = append(factors, f)
+				sizes = append(sizes, len(f.values))
+			}
+		}
+
+		var setup mqswag.NodeList
+		for _, def := range inputs(node) {
+			if producer := producers[def]; producer != nil && producer != node {
+				setup = append(setup, producer)
+			}
+		}
+
+		testSuite := CreateTestSuite(fmt.Sprintf("%s -- pairwise", mqswag.OperationKey(node.GetMethod(), node.GetName())), nil, testPlan)
+		testId := 0
+		add := func(n *mqswag.DAGNode) *Test {
+			testId++
+			t := CreateTestFromOp(n, testId)
+			testSuite.Tests = append(testSuite.Tests, t)
+			return t
+		}
+		rows := allPairs(sizes, max)
+		for i, row := range rows {
+			if i == 0 || OperationMatches(node, mqswag.MethodDelete) {
+				for _, s := range setup {
+					add(s)
+				}
+			}
+			t := add(node)
+			for k, v := range row {
+				setParam(t, factors[k].param, factors[k].values[v])
+			}
+		}
+		testSuite.comment = fmt.Sprintf("Parameters combined: %d, tests: %d", len(factors), len(rows))
+		testPlan.Add(testSuite)
+	}
+	return testPlan, nil
+}
@@ -0,0 +1,82 @@
+package mqplan
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func TestAllPairs(t *testing.T) {
+	sizes := []int{3, 3, 2, 2, 4}
+	rows := allPairs(sizes, 0)
+	for i := range sizes {
+		for j := i + 1; j < len(sizes); j++ {
+			for a := 0; a < sizes[i]; a++ {
+				for b := 0; b < sizes[j]; b++ {
+					found := false
+					for _, row := range rows {
+						found = found || (row[i] == a && row[j] == b)
+					}
+					if !found {
+						t.Errorf("factors %d and %d: the pair %d, %d is not covered", i, j, a, b)
+					}
+				}
+			}
+		}
+	}
+	// 216 combinations in all, the two largest factors need 12 at least.
+	if len(rows) < 12 || len(rows) > 20 {
+		t.Errorf("expected between 12 and 20 rows, got %d", len(rows))
+	}
+	if capped := allPairs(sizes, 5); len(capped) != 5 {
+		t.Errorf("expected the rows capped at 5, got %d", len(capped))
+	}
+	if single := allPairs([]int{3}, 0); len(single) != 3 {
+		t.Errorf("expected a row per value of a single factor, got %d", len(single))
+	}
+}
+
+func TestPairwiseTestPlan(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := mqswag.NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	dag.Sort()
+
+	filter := &mqswag.OperationFilter{Paths: mqswag.StringList{"/pet/findByStatus", "/store/order/*"}}
+	plan, err := GeneratePairwiseTestPlan(swagger, dag, filter, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite := plan.SuiteMap["get /pet/findByStatus -- pairwise"]
+	if suite == nil {
+		t.Fatalf("expected a suite for get /pet/findByStatus")
+	}
+	statuses := make(map[interface{}]bool)
+	for _, test := range suite.Tests {
+		if status, ok := test.QueryParams["status"]; ok {
+			statuses[status] = true
+		} else if test.isAbsent("status") {
+			statuses["absent"] = true
+		} else {
+			t.Fatalf("expected the status set or absent in every test")
+		}
+	}
+	// The three values and absent.
+	if len(statuses) != 4 || !statuses["absent"] {
+		t.Errorf("expected every status value tested, got %v", statuses)
+	}
+
+	// The order is created again before every delete.
+	suite = plan.SuiteMap["delete /store/order/{orderId} -- pairwise"]
+	if suite == nil || len(suite.Tests) != 2 || suite.Tests[0].Method != mqswag.MethodPost {
+		t.Errorf("expected the order created before the delete")
+	}
+}
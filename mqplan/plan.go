@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -72,6 +73,10 @@ type TestSuite struct {
 	TestParams `yaml:",inline,omitempty" json:",inline,omitempty"`
 	Strict     bool
 
+	// The request timeout and retry policy set by the suite's meqa_init, if any.
+	Timeout string
+	Retry   *Retry
+
 	// Authentication
 	Username string
 	Password string
@@ -98,6 +103,35 @@ func CreateTestSuite(name string, tests []*Test, plan *TestPlan) *TestSuite {
 	return &c
 }
 
+// TLS is how the server is called over TLS, e.g.
+//
+//	tls:
+//	  ca: certs/ca.pem
+//	  cert: certs/client.pem
+//	  key: certs/client.key
+//	  serverName: api.internal
+//
+// The relative paths are taken from the directory of the plan file.
+type TLS struct {
+	CA         string `yaml:"ca,omitempty"`
+	Cert       string `yaml:"cert,omitempty"`
+	Key        string `yaml:"key,omitempty"`
+	ServerName string `yaml:"serverName,omitempty"`
+	Insecure   bool   `yaml:"insecure,omitempty"`
+}
+
+// resolvePaths takes the relative paths from the directory dir.
+func (t *TLS) resolvePaths(dir string) {
+	if t == nil {
+		return
+	}
+	for _, p := range []*string{&t.CA, &t.Cert, &t.Key} {
+		if len(*p) > 0 && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+}
+
 // Represents all the test suites in the DSL.
 type TestPlan struct {
 	SuiteMap  map[string](*TestSuite)
@@ -109,16 +143,51 @@ type TestPlan struct {
 	TestParams `yaml:",inline,omitempty" json:",inline,omitempty"`
 	Strict     bool
 
+	// The default request timeout and retry policy of the tests.
+	Timeout string
+	Retry   *Retry
+
+	// The max requests per second and requests in flight, 0 for no limit. The Limiter enforces them.
+	RPS         float64
+	MaxInFlight int
+
+	// The TLS settings of the plan's meqa_init, nil if there are none.
+	TLS *TLS
+
 	// Authentication
 	Username string
 	Password string
 	ApiToken string
 
+	// Whether the curl commands and the recorded traffic show the credentials in clear text.
+	ShowSecrets bool
+
+	// When set, all the request/response exchanges are recorded.
+	Recorder *HarRecorder
+
+	// When set, only the tests of the operations it selects are run.
+	Filter *mqswag.OperationFilter
+
+	// When set, the objects created by the tests are deleted after the suites or the run.
+	Cleaner *Cleaner
+
+	// When set, the DB state is dumped after the tests.
+	DBDumper *DBDumper
+
+	// When set, the objects the suites create, update and delete are applied to the plan's DB too, and the
+	// suites look up the objects they don't have there. Only for the runs that load or save the DB, the
+	// suites are isolated otherwise.
+	SharedDB bool
+
+	// When set, the requests of all the suites are throttled.
+	Limiter *Limiter
+
 	// Run result.
 	resultList   []*Test
 	ResultCounts map[string]int
 
-	comment string
+	comment   string
+	initTests []*Test // The meqa_init tests loaded, which are applied to the plan rather than added as a suite.
 }
 
 // Add a new TestSuite, returns whether the Case is successfully added.
@@ -148,7 +217,23 @@ func (plan *TestPlan) AddFromString(data string) error {
 				t.Init(nil)
 				(&plan.TestParams).Copy(&t.TestParams)
 				plan.Strict = t.Strict
+				if len(t.Timeout) > 0 {
+					plan.Timeout = t.Timeout
+				}
+				if t.Retry != nil {
+					plan.Retry = t.Retry
+				}
+				if t.RPS > 0 {
+					plan.RPS = t.RPS
+				}
+				if t.MaxInFlight > 0 {
+					plan.MaxInFlight = t.MaxInFlight
+				}
+				if t.TLS != nil {
+					plan.TLS = t.TLS
+				}
 			}
+			plan.initTests = append(plan.initTests, testList...)
 
 			continue
 		}
@@ -177,6 +262,7 @@ func (plan *TestPlan) InitFromFile(path string, db *mqswag.DB) error {
 	for _, chunk := range chunks {
 		plan.AddFromString(chunk)
 	}
+	plan.TLS.resolvePaths(filepath.Dir(path))
 	return nil
 }
 
@@ -188,6 +274,11 @@ func WriteComment(comment string, f *os.File) {
 }
 
 func (plan *TestPlan) DumpToFile(path string) error {
+	return plan.dumpToFile(path, func(testSuite *TestSuite) interface{} { return testSuite.Tests })
+}
+
+// dumpToFile writes the suites, getting the yaml value of each suite's tests from suiteTests.
+func (plan *TestPlan) dumpToFile(path string, suiteTests func(*TestSuite) interface{}) error {
 	f, err := os.Create(path)
 	if err != nil {
 		return err
@@ -206,7 +297,7 @@ func (plan *TestPlan) DumpToFile(path string) error {
 		if err != nil {
 			return err
 		}
-		testMap := map[string]interface{}{testSuite.Name: testSuite.Tests}
+		testMap := map[string]interface{}{testSuite.Name: suiteTests(testSuite)}
 		caseBytes, err := yaml.Marshal(testMap)
 		if err != nil {
 			return err
@@ -230,7 +321,19 @@ func (plan *TestPlan) WriteResultToFile(path string) error {
 
 	tc.Tests = append(tc.Tests, plan.resultList...)
 
-	return p.DumpToFile(path)
+	return p.dumpToFile(path, func(testSuite *TestSuite) interface{} {
+		var results []testResult
+		for _, t := range testSuite.Tests {
+			results = append(results, testResult{t, t.curl})
+		}
+		return results
+	})
+}
+
+// testResult is a test in the result file, with the curl command that reproduces it.
+type testResult struct {
+	*Test `yaml:",inline"`
+	Curl  string `yaml:"curl,omitempty"`
 }
 
 func (plan *TestPlan) LogErrors() {
@@ -238,11 +341,17 @@ func (plan *TestPlan) LogErrors() {
 	fmt.Printf("-----------------------------Errors----------------------------------\n")
 	fmt.Print(mqutil.END)
 	for _, t := range plan.resultList {
-		if t.responseError != nil || t.schemaError != nil {
+		if t.err != nil || t.responseError != nil || t.schemaError != nil {
 			fmt.Print(mqutil.AQUA)
 			fmt.Println("--------")
 			fmt.Printf("%v: %v\n", t.Path, t.Name)
 			fmt.Print(mqutil.END)
+			t.printCurl()
+		}
+		if t.err != nil {
+			fmt.Print(mqutil.RED)
+			fmt.Println(t.err)
+			fmt.Print(mqutil.END)
 		}
 		if t.responseError != nil {
 			fmt.Print(mqutil.RED)
@@ -272,6 +381,9 @@ func (plan *TestPlan) PrintSummary() {
 	fmt.Printf("%v: %v\n", mqutil.SchemaMismatch, plan.ResultCounts[mqutil.SchemaMismatch])
 	fmt.Print(mqutil.AQUA)
 	fmt.Printf("%v: %v\n", mqutil.Total, plan.ResultCounts[mqutil.Total])
+	if plan.ResultCounts[mqutil.Retries] > 0 {
+		fmt.Printf("%v: %v\n", mqutil.Retries, plan.ResultCounts[mqutil.Retries])
+	}
 	fmt.Print(mqutil.END)
 }
 
@@ -281,6 +393,7 @@ func (plan *TestPlan) Init(swagger *mqswag.Swagger, db *mqswag.DB) {
 	plan.SuiteMap = make(map[string]*TestSuite)
 	plan.SuiteList = nil
 	plan.resultList = nil
+	plan.initTests = nil
 }
 
 // Run a named TestSuite in the test plan.
@@ -298,7 +411,14 @@ func (plan *TestPlan) Run(name string, parentTest *Test) (map[string]int, error)
 	}()
 	resultCounts[mqutil.Total] = len(tc.Tests)
 	resultCounts[mqutil.Failed] = 0
-	for _, test := range tc.Tests {
+	tests := plan.selectedTests(tc)
+	resultCounts[mqutil.Skipped] = len(tc.Tests) - len(tests)
+	for i, test := range tests {
+		if test.Obsolete {
+			mqutil.Logger.Printf("skipping the obsolete test %s", test.Name)
+			resultCounts[mqutil.Skipped]++
+			continue
+		}
 		if len(test.Ref) != 0 {
 			test.Strict = tc.Strict
 			resultCounts, err := plan.Run(test.Ref, test)
@@ -312,6 +432,12 @@ func (plan *TestPlan) Run(name string, parentTest *Test) (map[string]int, error)
 			// Apply the parameters to the test suite.
 			(&tc.TestParams).Copy(&test.TestParams)
 			tc.Strict = test.Strict
+			if len(test.Timeout) > 0 {
+				tc.Timeout = test.Timeout
+			}
+			if test.Retry != nil {
+				tc.Retry = test.Retry
+			}
 			continue
 		}
 
@@ -327,13 +453,21 @@ func (plan *TestPlan) Run(name string, parentTest *Test) (map[string]int, error)
 		}
 		err := dup.Run(tc)
 		dup.err = err
+		if plan.DBDumper != nil {
+			if path, dumpErr := plan.DBDumper.Dump(dup, tc, err); dumpErr != nil {
+				mqutil.Logger.Printf("Error dumping the DB: %s", dumpErr.Error())
+			} else if len(path) > 0 {
+				fmt.Printf("... client DB dumped to %s\n", path)
+			}
+		}
 		plan.resultList = append(plan.resultList, dup)
 		if dup.schemaError != nil {
 			resultCounts[mqutil.SchemaMismatch]++
 		}
+		resultCounts[mqutil.Retries] += dup.Retries
 		if err != nil {
 			resultCounts[mqutil.Failed]++
-			resultCounts[mqutil.Skipped] = len(tc.Tests) - resultCounts[mqutil.Passed] - 1
+			resultCounts[mqutil.Skipped] += len(tests) - i - 1
 			return resultCounts, err
 		}
 		resultCounts[mqutil.Passed]++
@@ -341,6 +475,31 @@ func (plan *TestPlan) Run(name string, parentTest *Test) (map[string]int, error)
 	return resultCounts, nil
 }
 
+// selectedTests returns the tests of the suite whose operations the plan's filter selects, with the earlier
+// tests they refer to through {{test.section.param}} and the suite's meqa_init. The tests referring to
+// other suites are kept, the filter applies to the tests of those suites.
+func (plan *TestPlan) selectedTests(tc *TestSuite) []*Test {
+	if plan.Filter.IsEmpty() {
+		return tc.Tests
+	}
+	var tests []*Test
+	if selected := tc.filterTests(func(t *Test) bool {
+		return len(t.Ref) > 0 || (len(t.Path) > 0 && plan.Filter.MatchesPath(plan.swagger, t.Method, t.Path))
+	}); selected != nil {
+		tests = selected.Tests
+	}
+	kept := make(map[*Test]bool)
+	for _, t := range tests {
+		kept[t] = true
+	}
+	for _, t := range tc.Tests {
+		if !kept[t] {
+			mqutil.Logger.Printf("skipping the test %s, its operation is filtered out", t.Name)
+		}
+	}
+	return tests
+}
+
 // The current global TestPlan
 var Current TestPlan
 
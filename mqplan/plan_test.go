@@ -0,0 +1,72 @@
+package mqplan
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+const filteredPlan = `
+pets:
+  - name: meqa_init
+    timeout: 5s
+  - name: create
+    path: /pet
+    method: post
+  - name: read
+    path: /pet/{petId}
+    method: get
+    pathParams:
+      petId: "{{create.outputs.id}}"
+  - name: read again
+    path: /pet/{petId}
+    method: get
+    pathParams:
+      petId: "{{create.outputs.id}}"
+  - name: remove
+    path: /pet/{petId}
+    method: delete
+`
+
+func runFiltered(t *testing.T, entries ...*HarEntry) map[string]int {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+
+	har := &Har{}
+	har.Log.Entries = entries
+	defer useTransport(NewReplayer(har))()
+
+	plan := &TestPlan{}
+	plan.Init(swagger, db)
+	plan.Filter = &mqswag.OperationFilter{Paths: mqswag.StringList{"/pet/*"}, Methods: mqswag.StringList{"get"}}
+	if err = plan.AddFromString(filteredPlan); err != nil {
+		t.Fatal(err)
+	}
+	counts, _ := plan.Run("pets", nil)
+	return counts
+}
+
+func TestRunFiltered(t *testing.T) {
+	// The create is filtered out but the reads refer to it, the remove is skipped.
+	counts := runFiltered(t,
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 200, `{"id": 7, "name": "doggie"}`))
+	if counts[mqutil.Passed] != 3 || counts[mqutil.Failed] != 0 || counts[mqutil.Skipped] != 1 {
+		t.Errorf("expected 3 tests passed and the remove skipped, got %v", counts)
+	}
+
+	// After a failure, the tests not run are added to the ones filtered out.
+	counts = runFiltered(t,
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 500, `{"message": "error"}`))
+	if counts[mqutil.Passed] != 1 || counts[mqutil.Failed] != 1 || counts[mqutil.Skipped] != 2 {
+		t.Errorf("expected the read failed, read again and remove skipped, got %v", counts)
+	}
+}
@@ -0,0 +1,140 @@
+package mqplan
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/gbatanov/meqa/mqswag"
+)
+
+const (
+	DefaultRandomCount  = 5
+	DefaultRandomLength = 10
+)
+
+// randomWalker builds the call sequences. An operation can only be called once the objects it uses
+// exist, i.e. once one of the operations producing them has been called. The objects that no operation
+// produces are generated along with the requests, so they don't hold anything up.
+type randomWalker struct {
+	rnd        *rand.Rand
+	operations mqswag.NodeList // The operations to pick from, in weight order.
+	deletes    mqswag.NodeList
+	producers  map[*mqswag.DAGNode]mqswag.NodeList // The definitions to the operations producing them.
+}
+
+// inputs returns the definitions the operation uses.
+func inputs(op *mqswag.DAGNode) mqswag.NodeList {
+	var defs mqswag.NodeList
+	for _, p := range op.Predecessors() {
+		if p.GetType() == mqswag.TypeDef {
+			defs = append(defs, p)
+		}
+	}
+	return defs
+}
+
+func (w *randomWalker) enabled(op *mqswag.DAGNode, created map[*mqswag.DAGNode]bool) bool {
+	for _, def := range inputs(op) {
+		if !created[def] && len(w.producers[def]) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sequence builds a sequence of length calls, followed by the deletes of the objects created, the last
+// created deleted first.
+func (w *randomWalker) sequence(length int) mqswag.NodeList {
+	var calls mqswag.NodeList
+	created := make(map[*mqswag.DAGNode]bool)
+	var createdOrder mqswag.NodeList
+	for len(calls) < length {
+		var candidates mqswag.NodeList
+		for _, op := range w.operations {
+			if w.enabled(op, created) {
+				candidates = append(candidates, op)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		op := candidates[w.rnd.Intn(len(candidates))]
+		calls = append(calls, op)
+		for _, c := range op.Children {
+			if c.GetType() == mqswag.TypeDef && !created[c] {
+				created[c] = true
+				createdOrder = append(createdOrder, c)
+			}
+		}
+	}
+
+	deleted := make(map[*mqswag.DAGNode]bool)
+	for i := len(createdOrder) - 1; i >= 0; i-- {
+		for _, op := range w.deletes {
+			if deleted[op] || !w.enabled(op, created) {
+				continue
+			}
+			for _, def := range inputs(op) {
+				if def == createdOrder[i] {
+					calls = append(calls, op)
+					deleted[op] = true
+					break
+				}
+			}
+		}
+	}
+	return calls
+}
+
+// GenerateRandomTestPlan generates count test suites, each a random sequence of length calls that respects
+// the dependencies: the objects are created before they are used, and deleted at the end. The operations
+// not selected by the filter are left out, except the ones that create objects. The same seed generates
+// the same plan, a seed of 0 means a new random seed every time.
+func GenerateRandomTestPlan(swagger *mqswag.Swagger, dag *mqswag.DAG, filter *mqswag.OperationFilter, count int,
+	length int, seed int64) (*TestPlan, error) {
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	w := &randomWalker{rnd: rand.New(rand.NewSource(seed)), producers: make(map[*mqswag.DAGNode]mqswag.NodeList)}
+	for _, n := range dag.TopologicalOrder() {
+		if n.GetType() != mqswag.TypeOp {
+			continue
+		}
+		produces := false
+		for _, c := range n.Children {
+			if c.GetType() == mqswag.TypeDef {
+				w.producers[c] = append(w.producers[c], n)
+				produces = true
+			}
+		}
+		if !filter.MatchesNode(n) && !produces {
+			continue
+		}
+		if OperationMatches(n, mqswag.MethodDelete) {
+			w.deletes = append(w.deletes, n)
+		} else {
+			w.operations = append(w.operations, n)
+		}
+	}
+
+	testPlan := &TestPlan{}
+	testPlan.Init(swagger, nil)
+	testPlan.comment = fmt.Sprintf(`
+In this test plan, every test suite is a random sequence of calls. The objects are created before
+they are used, and deleted at the end. Use the same seed to generate the same plan: %d
+`, seed)
+	addInitTestSuite(testPlan)
+
+	for i := 1; i <= count; i++ {
+		testSuite := CreateTestSuite(fmt.Sprintf("random sequence %d", i), nil, testPlan)
+		for j, op := range w.sequence(length) {
+			testSuite.Tests = append(testSuite.Tests, CreateTestFromOp(op, j+1))
+		}
+		if len(testSuite.Tests) > 0 {
+			testPlan.Add(testSuite)
+		}
+	}
+	return testPlan, nil
+}
@@ -0,0 +1,57 @@
+package mqplan
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func TestRandomTestPlan(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := mqswag.NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	dag.Sort()
+
+	plan, err := GenerateRandomTestPlan(swagger, dag, nil, 20, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := GenerateRandomTestPlan(swagger, dag, nil, 20, 8, 1)
+	if len(plan.SuiteList) != 21 || len(again.SuiteList) != 21 {
+		t.Fatalf("expected meqa_init and 20 sequences, got %d", len(plan.SuiteList))
+	}
+
+	// The pets are created by post /pet, the orders by post /store/order.
+	creates := map[string]string{"post /pet": "pet", "post /store/order": "order"}
+	uses := map[string]string{"put /pet": "pet", "get /pet/{petId}": "pet", "post /store/order": "pet",
+		"get /store/order/{orderId}": "order"}
+	for i, suite := range plan.SuiteList[1:] {
+		if !testEquals(suite.Tests[0], again.SuiteList[i+1].Tests[0]) || len(suite.Tests) != len(again.SuiteList[i+1].Tests) {
+			t.Errorf("%s: the same seed should generate the same sequence", suite.Name)
+		}
+		created := make(map[string]bool)
+		deleting := false
+		for _, test := range suite.Tests {
+			op := mqswag.OperationKey(test.Method, test.Path)
+			if class, ok := uses[op]; ok && !created[class] {
+				t.Errorf("%s: %s is called before a %s is created", suite.Name, op, class)
+			}
+			if test.Method == mqswag.MethodDelete {
+				deleting = true
+			} else if deleting {
+				t.Errorf("%s: %s is called after the deletes", suite.Name, op)
+			}
+			if class, ok := creates[op]; ok {
+				created[class] = true
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package mqplan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+// LoadHarFromFile reads a HAR file, e.g. one written by HarRecorder.
+func LoadHarFromFile(path string) (*Har, error) {
+	harBytes, err := os.ReadFile(path)
+	if err != nil {
+		mqutil.Logger.Printf("Can't open the following file: %s", path)
+		return nil, err
+	}
+	har := &Har{}
+	err = json.Unmarshal(harBytes, har)
+	if err != nil {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid HAR file %s: %s", path, err.Error()))
+	}
+	return har, nil
+}
+
+var pathParamRegexp = regexp.MustCompile(`\{[^/]*\}`)
+
+// templateRegexp turns a swagger path template into a regexp that matches the end of a request path.
+func templateRegexp(template string) *regexp.Regexp {
+	parts := pathParamRegexp.Split(template, -1)
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.MustCompile(strings.Join(parts, "[^/]+") + "$")
+}
+
+type replayEntry struct {
+	entry   *HarEntry
+	method  string
+	prefix  string         // The part of the url path before the path template, e.g. the base path.
+	matcher *regexp.Regexp // Matches the path template. nil if the entry doesn't have one.
+	path    string         // The url path, used when there is no path template.
+	params  int            // The number of path params in the template. Fewer means more specific.
+	body    interface{}
+	used    bool
+}
+
+// splitPath checks whether the url path matches the entry's path template. It returns the prefix
+// in front of the template.
+func (e *replayEntry) splitPath(path string) (string, bool) {
+	if e.matcher == nil {
+		return "", path == e.path
+	}
+	loc := e.matcher.FindStringIndex(path)
+	if loc == nil {
+		return "", false
+	}
+	return path[:loc[0]], true
+}
+
+func decodeBody(text string) interface{} {
+	if len(text) == 0 {
+		return nil
+	}
+	var body interface{}
+	d := json.NewDecoder(strings.NewReader(text))
+	d.UseNumber()
+	if d.Decode(&body) != nil {
+		return text
+	}
+	return body
+}
+
+// Replayer serves the responses from a recording instead of sending the requests over the network.
+// It implements http.RoundTripper, so it can be used as the transport of the http client. Requests
+// are matched to the recorded ones by method, path template and body. When several recorded requests
+// match, they are served in the recorded order.
+type Replayer struct {
+	entries []*replayEntry
+	mutex   sync.Mutex
+}
+
+func NewReplayer(har *Har) *Replayer {
+	r := &Replayer{}
+	for _, e := range har.Log.Entries {
+		u, err := url.Parse(e.Request.URL)
+		if err != nil {
+			mqutil.Logger.Printf("skipping recorded request with invalid url %s", e.Request.URL)
+			continue
+		}
+		entry := &replayEntry{entry: e, method: strings.ToUpper(e.Request.Method), path: u.Path}
+		if len(e.Path) > 0 {
+			entry.matcher = templateRegexp(e.Path)
+			entry.params = len(pathParamRegexp.FindAllString(e.Path, -1))
+			entry.prefix, _ = entry.splitPath(u.Path)
+		}
+		if e.Request.PostData != nil {
+			entry.body = decodeBody(e.Request.PostData.Text)
+		}
+		r.entries = append(r.entries, entry)
+	}
+	return r
+}
+
+func LoadReplayerFromFile(path string) (*Replayer, error) {
+	har, err := LoadHarFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(har), nil
+}
+
+// find returns the recorded entry that best matches the request.
+func (r *Replayer) find(method string, path string, body interface{}) *replayEntry {
+	var candidates []*replayEntry
+	for _, e := range r.entries {
+		if e.method != method {
+			continue
+		}
+		prefix, ok := e.splitPath(path)
+		if !ok || prefix != e.prefix {
+			continue
+		}
+		// e.g. /pet/findByStatus matches both /pet/findByStatus and /pet/{petId}, only keep the former.
+		if len(candidates) > 0 && candidates[0].params > e.params {
+			candidates = nil
+		}
+		if len(candidates) == 0 || candidates[0].params == e.params {
+			candidates = append(candidates, e)
+		}
+	}
+	// Prefer the unused entry with the same body, then any unused entry. Once all the candidates are
+	// used, keep serving the last one.
+	for _, e := range candidates {
+		if !e.used && reflect.DeepEqual(e.body, body) {
+			return e
+		}
+	}
+	for _, e := range candidates {
+		if !e.used {
+			return e
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[len(candidates)-1]
+	}
+	return nil
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body interface{}
+	if req.Body != nil {
+		bodyBytes, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = decodeBody(string(bodyBytes))
+	}
+
+	r.mutex.Lock()
+	e := r.find(req.Method, req.URL.Path, body)
+	if e != nil {
+		e.used = true
+	}
+	r.mutex.Unlock()
+	if e == nil {
+		return nil, mqutil.NewError(mqutil.ErrNotFound, fmt.Sprintf("no recorded response for %s %s", req.Method, req.URL.String()))
+	}
+
+	recorded := &e.entry.Response
+	if recorded.Status == 0 {
+		return nil, mqutil.NewError(mqutil.ErrHttp, fmt.Sprintf("the recorded request %s %s got no response", req.Method, req.URL.String()))
+	}
+	resp := &http.Response{
+		StatusCode: recorded.Status,
+		Status:     strings.TrimSpace(strconv.Itoa(recorded.Status) + " " + recorded.StatusText),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+	if len(recorded.HTTPVersion) > 0 {
+		resp.Proto = recorded.HTTPVersion
+	}
+	for _, h := range recorded.Headers {
+		// The body is served decoded, whatever the encoding on the wire was.
+		if !strings.EqualFold(h.Name, "Content-Encoding") && !strings.EqualFold(h.Name, "Content-Length") {
+			resp.Header.Add(h.Name, h.Value)
+		}
+	}
+	text := []byte(recorded.Content.Text)
+	resp.ContentLength = int64(len(text))
+	resp.Body = io.NopCloser(bytes.NewReader(text))
+	return resp, nil
+}
@@ -0,0 +1,152 @@
+package mqplan
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+	"gopkg.in/resty.v1"
+)
+
+// useTransport sets the transport of the default resty client, and returns the function restoring the
+// previous one.
+func useTransport(transport http.RoundTripper) func() {
+	client := resty.DefaultClient.GetClient()
+	previous := client.Transport
+	client.Transport = transport
+	return func() { client.Transport = previous }
+}
+
+func harEntry(method string, url string, path string, status int, body string) *HarEntry {
+	e := &HarEntry{Path: path}
+	e.Request.Method = method
+	e.Request.URL = url
+	e.Response.Status = status
+	e.Response.Headers = []HarNameValue{{"Content-Type", "application/json"}}
+	e.Response.Content.Text = body
+	return e
+}
+
+const replayPlan = `
+pet lifecycle:
+  - name: create
+    path: /pet
+    method: post
+  - name: meqa_init
+    strict: true
+  - name: read
+    path: /pet/{petId}
+    method: get
+    pathParams:
+      petId: "{{create.outputs.id}}"
+`
+
+func runReplay(t *testing.T, entries ...*HarEntry) map[string]int {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+
+	har := &Har{}
+	har.Log.Entries = entries
+	defer useTransport(NewReplayer(har))()
+
+	plan := &TestPlan{}
+	plan.Init(swagger, db)
+	err = plan.AddFromString(replayPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The meqa_init in the middle of the suite makes the read strict, but not the create.
+	counts, _ := plan.Run("pet lifecycle", nil)
+	return counts
+}
+
+func TestReplay(t *testing.T) {
+	counts := runReplay(t,
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 200, `{"id": 7, "name": "doggie"}`))
+	if counts[mqutil.Passed] != 2 || counts[mqutil.Failed] != 0 {
+		t.Errorf("expected both tests to pass, got %v", counts)
+	}
+}
+
+func TestReplayDetectsMismatch(t *testing.T) {
+	// The server returns a pet that's different from the one created, which the strict GET must catch.
+	counts := runReplay(t,
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 200, `{"id": 7, "name": "kitty"}`))
+	if counts[mqutil.Passed] != 1 || counts[mqutil.Failed] != 1 {
+		t.Errorf("expected the read to fail, got %v", counts)
+	}
+}
+
+func TestReplayMatching(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	har := &Har{}
+	har.Log.Entries = []*HarEntry{
+		harEntry("GET", "http://h/v2/pet/1", "/pet/{petId}", 200, `{"id": 1}`),
+		harEntry("GET", "http://h/v2/pet/findByStatus", "/pet/findByStatus", 200, `[]`),
+		harEntry("GET", "http://h/v2/pet/2", "/pet/{petId}", 200, `{"id": 2}`),
+	}
+	r := NewReplayer(har)
+	get := func(url string) *HarEntry {
+		req, _ := http.NewRequest("GET", url, nil)
+		e := r.find(req.Method, req.URL.Path, nil)
+		if e == nil {
+			return nil
+		}
+		e.used = true
+		return e.entry
+	}
+	if e := get("http://h/v2/pet/findByStatus"); e != har.Log.Entries[1] {
+		t.Errorf("findByStatus should match the literal path")
+	}
+	if e := get("http://h/v2/pet/42"); e != har.Log.Entries[0] {
+		t.Errorf("expected the first recorded pet")
+	}
+	if e := get("http://h/v2/pet/43"); e != har.Log.Entries[2] {
+		t.Errorf("expected the second recorded pet")
+	}
+	if e := get("http://h/v2/pet/44"); e != har.Log.Entries[2] {
+		t.Errorf("expected the last recorded pet to be served again")
+	}
+	if e := get("http://h/v1/pet/1"); e != nil {
+		t.Errorf("a different base path shouldn't match")
+	}
+}
+
+func TestSuitesIsolated(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+
+	har := &Har{}
+	har.Log.Entries = []*HarEntry{
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 200, `{"id": 7, "name": "doggie"}`),
+	}
+	defer useTransport(NewReplayer(har))()
+
+	plan := &TestPlan{}
+	plan.Init(swagger, db)
+	if err = plan.AddFromString(replayPlan); err != nil {
+		t.Fatal(err)
+	}
+	if counts, _ := plan.Run("pet lifecycle", nil); counts[mqutil.Passed] != 2 {
+		t.Fatalf("expected both tests to pass, got %v", counts)
+	}
+	// Without SharedDB, the objects of a suite don't leak to the later suites through the global DB.
+	if pets := db.Entries("Pet"); len(pets) != 0 {
+		t.Errorf("expected the global DB to stay empty, got %d pets", len(pets))
+	}
+}
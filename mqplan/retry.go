@@ -0,0 +1,243 @@
+package mqplan
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+	"gopkg.in/resty.v1"
+)
+
+const (
+	DefaultRequestTimeout = time.Minute
+	DefaultBackoff        = 500 * time.Millisecond
+	DefaultMaxBackoff     = 30 * time.Second
+)
+
+// Retry tells how the requests that hit a network error, a 5xx or a 429 are retried, e.g.
+//
+//	retry:
+//	  count: 3
+//	  backoff: 500ms
+//	  maxBackoff: 10s
+//
+// The delay starts at the backoff and doubles on every retry up to the maxBackoff, with a random jitter.
+// The Retry-After header of the response is honored when present, up to the maxBackoff. Only the idempotent
+// methods are retried, unless nonIdempotent is set, as a POST or a PATCH sent again may be applied twice.
+type Retry struct {
+	Count         int    `yaml:"count,omitempty"`
+	Backoff       string `yaml:"backoff,omitempty"`
+	MaxBackoff    string `yaml:"maxBackoff,omitempty"`
+	NonIdempotent bool   `yaml:"nonIdempotent,omitempty"`
+}
+
+// requestPolicy is the timeout and the retry policy a test sends its requests with.
+type requestPolicy struct {
+	timeout       time.Duration // No timeout if 0.
+	retries       int
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	nonIdempotent bool
+}
+
+// requestPolicy takes the timeout and the retry settings of the test, falling back to the ones of the suite
+// and then to the ones of the plan.
+func (t *Test) requestPolicy(tc *TestSuite) (*requestPolicy, error) {
+	timeout := t.Timeout
+	retry := t.Retry
+	if len(timeout) == 0 {
+		timeout = tc.Timeout
+	}
+	if retry == nil {
+		retry = tc.Retry
+	}
+	if tc.plan != nil {
+		if len(timeout) == 0 {
+			timeout = tc.plan.Timeout
+		}
+		if retry == nil {
+			retry = tc.plan.Retry
+		}
+	}
+
+	p := &requestPolicy{}
+	var err error
+	if p.timeout, err = parseDuration("timeout", timeout, DefaultRequestTimeout); err != nil {
+		return nil, err
+	}
+	if retry == nil {
+		return p, nil
+	}
+	if retry.Count < 0 {
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid retry count: %d", retry.Count))
+	}
+	p.retries = retry.Count
+	p.nonIdempotent = retry.NonIdempotent
+	if p.backoff, err = parseDuration("retry backoff", retry.Backoff, DefaultBackoff); err != nil {
+		return nil, err
+	}
+	if p.maxBackoff, err = parseDuration("retry maxBackoff", retry.MaxBackoff, DefaultMaxBackoff); err != nil {
+		return nil, err
+	}
+	if p.maxBackoff < p.backoff {
+		p.maxBackoff = p.backoff
+	}
+	return p, nil
+}
+
+// idempotent tells whether sending the request twice has the same effect as sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case mqswag.MethodGet, mqswag.MethodHead, mqswag.MethodOptions, mqswag.MethodPut, mqswag.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable tells whether the request is worth sending again, i.e. the call failed or the server is
+// unavailable or busy.
+func retryable(resp *resty.Response, callErr error) bool {
+	if callErr != nil {
+		return true
+	}
+	if resp == nil {
+		return false
+	}
+	status := resp.StatusCode()
+	return status >= 500 || status == http.StatusTooManyRequests
+}
+
+// retryAfter parses the Retry-After header, which is either the seconds to wait or the time to retry at.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// delay returns how long to wait before the retry, counting from 0. The wait the server asks for with
+// Retry-After is capped at the maxBackoff.
+func (p *requestPolicy) delay(retry int, resp *resty.Response) time.Duration {
+	if resp != nil && resp.RawResponse != nil {
+		if d, ok := retryAfter(resp.Header().Get("Retry-After"), time.Now()); ok {
+			if d > p.maxBackoff {
+				d = p.maxBackoff
+			}
+			return d
+		}
+	}
+	d := p.maxBackoff
+	if retry < 62 && p.backoff<<uint(retry) > 0 && p.backoff<<uint(retry) < d {
+		d = p.backoff << uint(retry)
+	}
+	// Half of the delay is random, so that the clients failing together don't retry together.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// send sends the request of the test, retrying as the test's policy allows. The error of the REST call is
+// kept in the test, the error returned is for the requests that can't be sent.
+func (t *Test) send(tc *TestSuite) (*resty.Response, error) {
+	p, err := t.requestPolicy(tc)
+	if err != nil {
+		return nil, err
+	}
+	if p.retries > 0 && !p.nonIdempotent && !idempotent(t.Method) {
+		mqutil.Logger.Printf("not retrying %s %s, the method isn't idempotent", strings.ToUpper(t.Method), t.Path)
+		p.retries = 0
+	}
+	for retry := 0; ; retry++ {
+		resp, err := t.sendOnce(tc, p.timeout)
+		if err != nil {
+			return nil, err
+		}
+		if retry >= p.retries || !retryable(resp, t.err) {
+			return resp, nil
+		}
+		reason := "the call failed"
+		if t.err == nil {
+			reason = "status " + strconv.Itoa(resp.StatusCode())
+		}
+		d := p.delay(retry, resp)
+		t.Retries++
+		fmt.Printf("... %s, retry %d of %d in %v\n", reason, retry+1, p.retries, d.Round(time.Millisecond))
+		mqutil.Logger.Printf("%s, retry %d of %d in %v", reason, retry+1, p.retries, d)
+		time.Sleep(d)
+	}
+}
+
+// sendOnce sends the request of the test once, giving up after the timeout.
+func (t *Test) sendOnce(tc *TestSuite, timeout time.Duration) (*resty.Response, error) {
+	if tc.plan != nil {
+		tc.plan.Limiter.Acquire()
+		defer tc.plan.Limiter.Release()
+	}
+	req := resty.R()
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		req.SetContext(ctx)
+	}
+	if len(tc.ApiToken) > 0 {
+		req.SetAuthToken(tc.ApiToken)
+	} else if len(tc.Username) > 0 {
+		req.SetBasicAuth(tc.Username, tc.Password)
+	}
+
+	path := GetBaseURL(t.db.Swagger) + t.SetRequestParameters(req)
+	var resp *resty.Response
+	var err error
+
+	t.startTime = time.Now()
+	switch t.Method {
+	case mqswag.MethodGet:
+		resp, err = req.Get(path)
+	case mqswag.MethodPost:
+		resp, err = req.Post(path)
+	case mqswag.MethodPut:
+		resp, err = req.Put(path)
+	case mqswag.MethodDelete:
+		resp, err = req.Delete(path)
+	case mqswag.MethodPatch:
+		resp, err = req.Patch(path)
+	case mqswag.MethodHead:
+		resp, err = req.Head(path)
+	case mqswag.MethodOptions:
+		resp, err = req.Options(path)
+	default:
+		return nil, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("Unknown method in test %s: %v", t.Name, t.Method))
+	}
+	t.stopTime = time.Now()
+	fmt.Printf("... call completed: %f seconds\n", t.stopTime.Sub(t.startTime).Seconds())
+	mqutil.Logger.Printf("%s %s", strings.ToUpper(t.Method), path)
+	if tc.plan != nil && tc.plan.Recorder != nil {
+		tc.plan.Recorder.Record(t, resp, tc.plan.ShowSecrets)
+	}
+
+	t.err = nil
+	if err != nil {
+		t.err = mqutil.NewError(mqutil.ErrHttp, err.Error())
+	} else {
+		mqutil.Logger.Print(resp.Status())
+		mqutil.Logger.Println(string(resp.Body()))
+	}
+	return resp, nil
+}
@@ -0,0 +1,132 @@
+package mqplan
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+	"gopkg.in/resty.v1"
+)
+
+const retryPlan = `
+meqa_init:
+  - name: meqa_init
+    timeout: 50ms
+    retry:
+      count: 2
+      backoff: 1ms
+      maxBackoff: 5ms
+      nonIdempotent: true
+pet create:
+  - name: create
+    path: /pet
+    method: post
+`
+
+func runRetry(t *testing.T, planString string, transport http.RoundTripper) (map[string]int, *Test) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+
+	defer useTransport(transport)()
+
+	plan := &TestPlan{}
+	plan.Init(swagger, db)
+	if err = plan.AddFromString(planString); err != nil {
+		t.Fatal(err)
+	}
+	counts, _ := plan.Run("pet create", nil)
+	if len(plan.resultList) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(plan.resultList))
+	}
+	return counts, plan.resultList[0]
+}
+
+func TestRetryOnUnavailable(t *testing.T) {
+	unavailable := harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 503, `{}`)
+	unavailable.Response.Headers = append(unavailable.Response.Headers, HarNameValue{"Retry-After", "0"})
+	har := &Har{}
+	har.Log.Entries = []*HarEntry{unavailable,
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie"}`)}
+
+	counts, create := runRetry(t, retryPlan, NewReplayer(har))
+	if counts[mqutil.Passed] != 1 || counts[mqutil.Retries] != 1 {
+		t.Errorf("expected the create to pass after a retry, got %v", counts)
+	}
+	if create.Retries != 1 {
+		t.Errorf("expected 1 retry in the result, got %d", create.Retries)
+	}
+}
+
+// hangingTransport never answers, until the request is cancelled.
+type hangingTransport struct {
+	calls int
+}
+
+func (h *hangingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.calls++
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestRequestTimeout(t *testing.T) {
+	transport := &hangingTransport{}
+	start := time.Now()
+	counts, create := runRetry(t, retryPlan, transport)
+	if counts[mqutil.Failed] != 1 {
+		t.Errorf("expected the create to time out, got %v", counts)
+	}
+	if transport.calls != 3 || create.Retries != 2 {
+		t.Errorf("expected the request to be sent 3 times, got %d calls and %d retries", transport.calls, create.Retries)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the timeout wasn't applied, the test took %v", elapsed)
+	}
+}
+
+func TestNoRetryNonIdempotent(t *testing.T) {
+	// Without nonIdempotent, a POST is sent once.
+	transport := &hangingTransport{}
+	counts, create := runRetry(t, strings.Replace(retryPlan, "nonIdempotent: true", "", 1), transport)
+	if counts[mqutil.Failed] != 1 || transport.calls != 1 || create.Retries != 0 {
+		t.Errorf("expected the create sent once, got %d calls and %d retries", transport.calls, create.Retries)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if d, ok := retryAfter("3", now); !ok || d != 3*time.Second {
+		t.Errorf("expected 3s, got %v %v", d, ok)
+	}
+	if d, ok := retryAfter("Mon, 01 Jan 2024 12:00:10 GMT", now); !ok || d != 10*time.Second {
+		t.Errorf("expected 10s, got %v %v", d, ok)
+	}
+	if _, ok := retryAfter("soon", now); ok {
+		t.Errorf("expected an invalid Retry-After to be ignored")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := &requestPolicy{retries: 10, backoff: 100 * time.Millisecond, maxBackoff: time.Second}
+	for retry, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		if d := p.delay(retry, nil); d < max/2 || d > max {
+			t.Errorf("retry %d: expected a delay between %v and %v, got %v", retry, max/2, max, d)
+		}
+	}
+	if d := p.delay(100, nil); d < p.maxBackoff/2 || d > p.maxBackoff {
+		t.Errorf("expected the delay to be capped, got %v", d)
+	}
+	resp := &resty.Response{RawResponse: &http.Response{Header: http.Header{"Retry-After": {"3600"}}}}
+	if d := p.delay(0, resp); d != p.maxBackoff {
+		t.Errorf("expected the Retry-After capped at the maxBackoff, got %v", d)
+	}
+}
@@ -0,0 +1,126 @@
+package mqplan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gbatanov/meqa/mqutil"
+	"gopkg.in/resty.v1"
+)
+
+const (
+	DefaultWaitInterval = time.Second
+	DefaultWaitTimeout  = 30 * time.Second
+)
+
+// WaitUntil makes a test repeat its request until the response meets the condition, for the operations
+// that complete asynchronously, e.g.
+//
+//	waitUntil:
+//	  status: 200
+//	  body:
+//	    state: done
+//	  interval: 2s
+//	  timeout: 1m
+//
+// The status is a status code, "success" or "fail", and defaults to "success". The body is matched the
+// same way as the expected body of the test. The interval and the timeout are durations like 500ms or 1m.
+type WaitUntil struct {
+	Status   interface{} `yaml:"status,omitempty"`
+	Body     interface{} `yaml:"body,omitempty"`
+	Interval string      `yaml:"interval,omitempty"`
+	Timeout  string      `yaml:"timeout,omitempty"`
+}
+
+// parseDuration parses the duration setting, the default if it isn't set.
+func parseDuration(name string, value string, defaultValue time.Duration) (time.Duration, error) {
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid %s: %s", name, value))
+	}
+	return d, nil
+}
+
+// holds tells whether the response meets the condition.
+func (w *WaitUntil) holds(resp *resty.Response) bool {
+	status := resp.StatusCode()
+	success := status >= 200 && status < 300
+	switch expected := w.Status.(type) {
+	case nil:
+		if !success {
+			return false
+		}
+	case int:
+		if status != expected {
+			return false
+		}
+	case string:
+		if (expected == "fail") == success {
+			return false
+		}
+	default:
+		return false
+	}
+	if w.Body == nil {
+		return true
+	}
+
+	// The condition goes through json too, so that its numbers are compared as json.Number.
+	var criteria, resultObj interface{}
+	conditionBytes, err := json.Marshal(w.Body)
+	if err != nil {
+		return false
+	}
+	d := json.NewDecoder(bytes.NewReader(conditionBytes))
+	d.UseNumber()
+	if d.Decode(&criteria) != nil {
+		return false
+	}
+	d = json.NewDecoder(bytes.NewReader(resp.Body()))
+	d.UseNumber()
+	if d.Decode(&resultObj) != nil {
+		return false
+	}
+	return mqutil.InterfaceEquals(criteria, resultObj)
+}
+
+// poll sends the request until the waitUntil condition holds or the timeout expires. It returns the last
+// response, and whether the condition held.
+func (t *Test) poll(tc *TestSuite) (*resty.Response, bool, error) {
+	interval, err := parseDuration("waitUntil interval", t.WaitUntil.Interval, DefaultWaitInterval)
+	if err != nil {
+		return nil, false, err
+	}
+	timeout, err := parseDuration("waitUntil timeout", t.WaitUntil.Timeout, DefaultWaitTimeout)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var resp *resty.Response
+	held := false
+	start := time.Now()
+	for t.Attempts = 1; ; t.Attempts++ {
+		resp, err = t.send(tc)
+		if err != nil {
+			return nil, false, err
+		}
+		if t.err == nil && t.WaitUntil.holds(resp) {
+			held = true
+			break
+		}
+		if time.Since(start)+interval > timeout {
+			break
+		}
+		fmt.Printf("... waiting for the condition, attempt %d, retrying in %v\n", t.Attempts, interval)
+		time.Sleep(interval)
+	}
+	t.WaitTime = time.Since(start).Round(time.Millisecond).String()
+	fmt.Printf("... waited %s, %d attempts\n", t.WaitTime, t.Attempts)
+	mqutil.Logger.Printf("waited %s, %d attempts, condition met: %v", t.WaitTime, t.Attempts, held)
+	return resp, held, nil
+}
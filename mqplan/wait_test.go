@@ -0,0 +1,85 @@
+package mqplan
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqswag"
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+const waitPlan = `
+pet sale:
+  - name: create
+    path: /pet
+    method: post
+  - name: sold
+    path: /pet/{petId}
+    method: get
+    pathParams:
+      petId: "{{create.outputs.id}}"
+    waitUntil:
+      body:
+        id: "{{create.outputs.id}}"
+        status: sold
+      interval: 10ms
+      timeout: 200ms
+`
+
+func runWait(t *testing.T, entries ...*HarEntry) (map[string]int, *Test) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := mqswag.CreateSwaggerFromURL("testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &mqswag.DB{}
+	db.Init(swagger)
+
+	har := &Har{}
+	har.Log.Entries = entries
+	defer useTransport(NewReplayer(har))()
+
+	plan := &TestPlan{}
+	plan.Init(swagger, db)
+	if err = plan.AddFromString(waitPlan); err != nil {
+		t.Fatal(err)
+	}
+	counts, _ := plan.Run("pet sale", nil)
+	if len(plan.resultList) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(plan.resultList))
+	}
+	return counts, plan.resultList[1]
+}
+
+func TestWaitUntil(t *testing.T) {
+	counts, sold := runWait(t,
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie", "status": "available"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 202, `{"id": 7, "name": "doggie", "status": "available"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 200, `{"id": 7, "name": "doggie", "status": "pending"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 200, `{"id": 7, "name": "doggie", "status": "sold"}`))
+	if counts[mqutil.Passed] != 2 || counts[mqutil.Failed] != 0 {
+		t.Errorf("expected both tests to pass, got %v", counts)
+	}
+	if sold.Attempts != 3 || len(sold.WaitTime) == 0 {
+		t.Errorf("expected 3 attempts with the wait time, got %d attempts in %q", sold.Attempts, sold.WaitTime)
+	}
+}
+
+func TestWaitUntilTimeout(t *testing.T) {
+	counts, sold := runWait(t,
+		harEntry("POST", "http://petstore.example.com/v2/pet", "/pet", 200, `{"id": 7, "name": "doggie", "status": "available"}`),
+		harEntry("GET", "http://petstore.example.com/v2/pet/7", "/pet/{petId}", 200, `{"id": 7, "name": "doggie", "status": "pending"}`))
+	if counts[mqutil.Passed] != 1 || counts[mqutil.Failed] != 1 {
+		t.Errorf("expected the wait to time out, got %v", counts)
+	}
+	if sold.Attempts < 2 {
+		t.Errorf("expected the request to be repeated, got %d attempts", sold.Attempts)
+	}
+}
+
+func TestWaitUntilInvalidInterval(t *testing.T) {
+	w := &WaitUntil{Interval: "soon"}
+	if _, err := parseDuration("waitUntil interval", w.Interval, DefaultWaitInterval); err == nil {
+		t.Errorf("expected an error for the invalid interval")
+	}
+}
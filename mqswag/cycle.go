@@ -0,0 +1,206 @@
+package mqswag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+// BrokenEdge is a dependency that was removed from the DAG because it was part of a circle.
+type BrokenEdge struct {
+	From     *DAGNode
+	To       *DAGNode
+	Strength EdgeStrength
+	Cycle    NodeList // The circle the dependency was in, starting and ending with From.
+}
+
+func (e *BrokenEdge) ToString() string {
+	var names []string
+	for _, n := range e.Cycle {
+		names = append(names, strings.TrimSpace(n.ToString()))
+	}
+	return fmt.Sprintf("%s -> %s (%s), in the circle: %s", strings.TrimSpace(e.From.ToString()),
+		strings.TrimSpace(e.To.ToString()), e.Strength, strings.Join(names, " -> "))
+}
+
+// sortedNodes returns all the nodes ordered by name, so that the results don't depend on map order.
+func (dag *DAG) sortedNodes() NodeList {
+	var names []string
+	for name := range dag.NameMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var nodes NodeList
+	for _, name := range names {
+		nodes = append(nodes, dag.NameMap[name])
+	}
+	return nodes
+}
+
+// cycles returns the strongly connected components that have circles in them, using Tarjan's algorithm.
+func (dag *DAG) cycles() []NodeList {
+	index := make(map[*DAGNode]int)
+	lowLink := make(map[*DAGNode]int)
+	onStack := make(map[*DAGNode]bool)
+	var stack NodeList
+	var result []NodeList
+	counter := 0
+
+	var connect func(node *DAGNode)
+	connect = func(node *DAGNode) {
+		index[node] = counter
+		lowLink[node] = counter
+		counter++
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, c := range node.Children {
+			if _, visited := index[c]; !visited {
+				connect(c)
+				if lowLink[c] < lowLink[node] {
+					lowLink[node] = lowLink[c]
+				}
+			} else if onStack[c] && index[c] < lowLink[node] {
+				lowLink[node] = index[c]
+			}
+		}
+
+		if lowLink[node] == index[node] {
+			var component NodeList
+			for {
+				n := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[n] = false
+				component = append(component, n)
+				if n == node {
+					break
+				}
+			}
+			_, selfLoop := node.strengths[node]
+			if len(component) > 1 || selfLoop {
+				result = append(result, component)
+			}
+		}
+	}
+
+	for _, node := range dag.sortedNodes() {
+		if _, visited := index[node]; !visited {
+			connect(node)
+		}
+	}
+	return result
+}
+
+// findPath finds the shortest path from one node to another, only going through the nodes in the component.
+func findPath(from *DAGNode, to *DAGNode, component map[*DAGNode]bool) NodeList {
+	previous := map[*DAGNode]*DAGNode{from: nil}
+	queue := NodeList{from}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if n == to {
+			var path NodeList
+			for ; n != nil; n = previous[n] {
+				path = append(NodeList{n}, path...)
+			}
+			return path
+		}
+		for _, c := range n.Children {
+			if _, seen := previous[c]; !seen && component[c] {
+				previous[c] = n
+				queue = append(queue, c)
+			}
+		}
+	}
+	return nil
+}
+
+// BreakCycles removes dependencies until there is no circle left. For every circle, the weakest
+// dependency is removed. Between dependencies of the same strength, the one of a node with a weak
+// reference into the circle goes first, as the spec marks that node's relations as loose. It returns the
+// dependencies removed.
+func (dag *DAG) BreakCycles() []*BrokenEdge {
+	var broken []*BrokenEdge
+	for {
+		components := dag.cycles()
+		if len(components) == 0 {
+			return broken
+		}
+		for _, component := range components {
+			members := make(map[*DAGNode]bool)
+			for _, n := range component {
+				members[n] = true
+			}
+			var weakest *BrokenEdge
+			weakestHinted := false
+			for _, n := range component {
+				for _, c := range n.Children {
+					if !members[c] {
+						continue
+					}
+					e := &BrokenEdge{From: n, To: c, Strength: n.Strength(c)}
+					hinted := c.hasWeakRefIn(members)
+					if weakest == nil || e.Strength < weakest.Strength ||
+						(e.Strength == weakest.Strength && hinted && !weakestHinted) ||
+						(e.Strength == weakest.Strength && hinted == weakestHinted && (e.From.Name < weakest.From.Name ||
+							(e.From.Name == weakest.From.Name && e.To.Name < weakest.To.Name))) {
+						weakest = e
+						weakestHinted = hinted
+					}
+				}
+			}
+			weakest.Cycle = append(findPath(weakest.To, weakest.From, members), weakest.To)
+			weakest.From.removeChild(weakest.To)
+			broken = append(broken, weakest)
+			mqutil.Logger.Printf("circular dependency, ignoring %s", weakest.ToString())
+		}
+	}
+}
+
+// ComputeWeights sets the weight of every node to be the length of the longest chain of dependencies
+// leading to it, so that every child is heavier than its parents. The DAG must not have circles.
+func (dag *DAG) ComputeWeights() error {
+	nodes := dag.sortedNodes()
+	inDegree := make(map[*DAGNode]int)
+	for _, n := range nodes {
+		for _, c := range n.Children {
+			inDegree[c]++
+		}
+	}
+	var queue NodeList
+	for _, n := range nodes {
+		n.Weight = 0
+		if inDegree[n] == 0 {
+			queue = append(queue, n)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, c := range n.Children {
+			if c.Weight <= n.Weight {
+				c.Weight = n.Weight + 1
+			}
+			inDegree[c]--
+			if inDegree[c] == 0 {
+				queue = append(queue, c)
+			}
+		}
+	}
+	if visited != len(nodes) {
+		return mqutil.NewError(mqutil.ErrInvalid, "circular dependency found when computing the weights")
+	}
+
+	dag.WeightList = nil
+	for _, n := range nodes {
+		for len(dag.WeightList) <= n.Weight {
+			dag.WeightList = append(dag.WeightList, nil)
+		}
+		dag.WeightList[n.Weight] = append(dag.WeightList[n.Weight], n)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package mqswag
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func TestBreakCycles(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := CreateSwaggerFromURL("testdata/cycle_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := NewDAG()
+	err = swagger.AddToDAG(dag)
+	if err != nil {
+		t.Fatalf("a circle shouldn't fail the DAG: %s", err.Error())
+	}
+	dag.Sort()
+	if err = dag.CheckWeight(); err != nil {
+		t.Fatal(err)
+	}
+
+	user := dag.NameMap[GetDAGName(TypeDef, "User", "")]
+	team := dag.NameMap[GetDAGName(TypeDef, "Team", "")]
+	if len(dag.BrokenEdges) == 0 {
+		t.Fatal("expected the circle between User and Team to be broken")
+	}
+	// Team.members is required, User.team is optional, so it's User's dependency on Team that goes.
+	first := dag.BrokenEdges[0]
+	if first.From != team || first.To != user || first.Strength != EdgeOptional {
+		t.Errorf("expected the optional Team -> User to be broken, got %s", first.ToString())
+	}
+	if user.Strength(team) != EdgeRequired {
+		t.Errorf("Team should still depend on User")
+	}
+	if user.Weight >= team.Weight {
+		t.Errorf("User should come before Team, got weights %d and %d", user.Weight, team.Weight)
+	}
+	if len(dag.cycles()) != 0 {
+		t.Errorf("circles left after breaking")
+	}
+}
+
+func TestDAGQueries(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := CreateSwaggerFromURL("../mqplan/testdata/petstore_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	dag.Sort()
+
+	pet := dag.NameMap[GetDAGName(TypeDef, "Pet", "")]
+	addPet := dag.NameMap[GetDAGName(TypeOp, "/pet", MethodPost)]
+	getOrder := dag.NameMap[GetDAGName(TypeOp, "/store/order/{orderId}", MethodGet)]
+
+	order := dag.TopologicalOrder()
+	if len(order) != len(dag.NameMap) {
+		t.Fatalf("expected %d nodes in the order, got %d", len(dag.NameMap), len(order))
+	}
+	position := make(map[*DAGNode]int)
+	for i, n := range order {
+		position[n] = i
+	}
+	for _, n := range order {
+		for _, c := range n.Children {
+			if position[c] <= position[n] {
+				t.Errorf("%s is ordered before %s which it depends on", c.Name, n.Name)
+			}
+		}
+	}
+
+	found := false
+	for _, n := range pet.Predecessors() {
+		found = found || n == addPet
+	}
+	if !found {
+		t.Errorf("Pet should depend on post /pet")
+	}
+	ancestors := getOrder.Ancestors()
+	if len(ancestors) == 0 || ancestors[len(ancestors)-1].Level() >= getOrder.Level() {
+		t.Errorf("the ancestors should be on lower levels")
+	}
+	descendants := addPet.Descendants()
+	found = false
+	for _, n := range descendants {
+		found = found || n == getOrder
+	}
+	if !found {
+		t.Errorf("get order should depend on post /pet indirectly, got %v", descendants)
+	}
+	total := 0
+	for l := 0; l < dag.Levels(); l++ {
+		total += len(dag.NodesAt(l))
+	}
+	if total != len(dag.NameMap) {
+		t.Errorf("every node should be on exactly one level")
+	}
+}
+
+func TestWeakReferences(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := CreateSwaggerFromURL("testdata/weak_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	def := func(name string) *DAGNode {
+		return dag.NameMap[GetDAGName(TypeDef, name, "")]
+	}
+
+	// A weak reference doesn't order the objects.
+	for _, c := range def("Author").Children {
+		if c == def("Note") {
+			t.Errorf("Note shouldn't depend on Author through the weak reference")
+		}
+	}
+	if !def("Note").weakRefs[def("Author")] {
+		t.Errorf("expected the weak reference of Note to Author recorded")
+	}
+
+	// All the dependencies in the circle A -> B -> C -> A are required. By name, A -> B would go, but
+	// C refers to A through a weak reference, so it's C's dependency on B that's ignored.
+	if len(dag.BrokenEdges) != 1 {
+		t.Fatalf("expected one dependency broken, got %d", len(dag.BrokenEdges))
+	}
+	broken := dag.BrokenEdges[0]
+	if broken.From != def("B") || broken.To != def("C") || broken.Strength != EdgeRequired {
+		t.Errorf("expected B -> C to be broken, got %s", broken.ToString())
+	}
+}
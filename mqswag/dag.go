@@ -9,20 +9,39 @@ import (
 	"github.com/gbatanov/meqa/mqutil"
 )
 
-const (
-	DAGDepth = 1000
-)
-
 // The traversal order is from this node to children. The children depend on the parent.
 // The children's weight would be bigger than the parent.
 type DAGNode struct {
 	Name     string
-	Weight   int // The weight determines which level it goes to in global DAG
+	Weight   int // The weight is the level of the node in the DAG, see ComputeWeights.
 	Priority int // The priority determines the sorting order within the same weight
 	Data     interface{}
 	Children NodeList
 
-	dag *DAG
+	dag       *DAG
+	parents   NodeList
+	strengths map[*DAGNode]EdgeStrength // The strength of the dependency of each child on this node.
+	weakRefs  map[*DAGNode]bool         // The nodes this one refers to through weak references, see BreakCycles.
+}
+
+// EdgeStrength tells how much a child depends on its parent. When there is a circular dependency, the
+// weakest dependency in the circle is ignored.
+type EdgeStrength int
+
+const (
+	EdgeWeak     EdgeStrength = iota // Through a reference tagged as weak. Not a dependency, see AddWeakRef.
+	EdgeOptional                     // Through an optional property.
+	EdgeRequired
+)
+
+func (s EdgeStrength) String() string {
+	switch s {
+	case EdgeWeak:
+		return "weak"
+	case EdgeOptional:
+		return "optional"
+	}
+	return "required"
 }
 
 func (node *DAGNode) ToString() string {
@@ -41,34 +60,6 @@ func (node *DAGNode) GetMethod() string {
 	return strings.Split(node.Name, FieldSeparator)[2]
 }
 
-// AdjustWeight changes the children's weight to be at least this node's weight + 1
-func (node *DAGNode) AdjustChildrenWeight(depList []*DAGNode) error {
-	// detect circular dependency
-	for i, n := range depList {
-		if node == n {
-			str := "Circular dependency detected (top depends on bottom):"
-			for j := i; j < len(depList); j++ {
-				str = str + "\n\t" + depList[j].ToString()
-			}
-			str = str + "\n\t" + node.ToString() + "\n"
-			return mqutil.NewError(mqutil.ErrInvalid, str)
-		}
-	}
-	// Add this node to the chain
-	depList = append(depList, node)
-	for _, c := range node.Children {
-		if c.Weight <= node.Weight {
-			err := node.dag.AdjustNodeWeight(c, node.Weight+1, depList)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	// pop this node out of the chain
-	depList = depList[:len(depList)-1]
-	return nil
-}
-
 func (node *DAGNode) CheckChildrenWeight() bool {
 	for _, c := range node.Children {
 		if c.Weight <= node.Weight {
@@ -81,21 +72,119 @@ func (node *DAGNode) CheckChildrenWeight() bool {
 	return true
 }
 
+// AddChild adds a required dependency: the child depends on this node.
 func (node *DAGNode) AddChild(child *DAGNode) error {
-	// Checks like these aren't necessary once our code works correctly. For now it makes catching bugs easier.
-	for _, c := range node.Children {
-		if c.Name == child.Name {
-			// Objects have unique name, therefore child has unique name.
-			return nil
+	return node.AddChildWithStrength(child, EdgeRequired)
+}
+
+// AddChildWithStrength adds a dependency of the given strength. Adding the same child again keeps the
+// stronger of the two. The weights are not adjusted until ComputeWeights is called, so the cycles can
+// be dealt with as a whole.
+func (node *DAGNode) AddChildWithStrength(child *DAGNode, strength EdgeStrength) error {
+	if node.dag == nil || node.dag != child.dag {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("adding a child from another DAG: %s -> %s", node.Name, child.Name))
+	}
+	if node.strengths == nil {
+		node.strengths = make(map[*DAGNode]EdgeStrength)
+	}
+	if existing, ok := node.strengths[child]; ok {
+		// Objects have unique name, therefore child has unique name.
+		if strength > existing {
+			node.strengths[child] = strength
 		}
+		return nil
 	}
+	node.strengths[child] = strength
 	node.Children = append(node.Children, child)
-	if child.Weight <= node.Weight {
-		return node.AdjustChildrenWeight(nil)
-	}
+	child.parents = append(child.parents, node)
 	return nil
 }
 
+// AddWeakRef records that the node refers to the parent through a weak reference. That doesn't make the
+// node depend on the parent, so it doesn't change the order. It only tells which dependency to ignore when
+// the node is in a circle.
+func (node *DAGNode) AddWeakRef(parent *DAGNode) {
+	if node.weakRefs == nil {
+		node.weakRefs = make(map[*DAGNode]bool)
+	}
+	node.weakRefs[parent] = true
+}
+
+// hasWeakRefIn tells whether the node refers to any of the nodes through a weak reference.
+func (node *DAGNode) hasWeakRefIn(nodes map[*DAGNode]bool) bool {
+	for n := range node.weakRefs {
+		if nodes[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// Strength returns the strength of the dependency of the child on this node.
+func (node *DAGNode) Strength(child *DAGNode) EdgeStrength {
+	return node.strengths[child]
+}
+
+// removeChild removes the dependency of the child on this node.
+func (node *DAGNode) removeChild(child *DAGNode) {
+	for i, c := range node.Children {
+		if c == child {
+			node.Children = append(node.Children[:i], node.Children[i+1:]...)
+			break
+		}
+	}
+	for i, p := range child.parents {
+		if p == node {
+			child.parents = append(child.parents[:i], child.parents[i+1:]...)
+			break
+		}
+	}
+	delete(node.strengths, child)
+}
+
+// Level returns the level of the node, which is the length of the longest chain of dependencies
+// leading to it. It's the same as the weight.
+func (node *DAGNode) Level() int {
+	return node.Weight
+}
+
+// Predecessors returns the nodes this node directly depends on.
+func (node *DAGNode) Predecessors() NodeList {
+	list := append(NodeList{}, node.parents...)
+	sort.Sort(list)
+	return list
+}
+
+// walk collects all the nodes reachable from the node through next, not including the node itself.
+func (node *DAGNode) walk(next func(*DAGNode) NodeList) NodeList {
+	visited := map[*DAGNode]bool{node: true}
+	var list NodeList
+	queue := NodeList{node}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, m := range next(n) {
+			if !visited[m] {
+				visited[m] = true
+				list = append(list, m)
+				queue = append(queue, m)
+			}
+		}
+	}
+	sort.Sort(list)
+	return list
+}
+
+// Ancestors returns all the nodes this node depends on, directly or indirectly, in topological order.
+func (node *DAGNode) Ancestors() NodeList {
+	return node.walk(func(n *DAGNode) NodeList { return n.parents })
+}
+
+// Descendants returns all the nodes that depend on this node, directly or indirectly, in topological order.
+func (node *DAGNode) Descendants() NodeList {
+	return node.walk(func(n *DAGNode) NodeList { return n.Children })
+}
+
 // AddDependencies adds the nodes named in the tags map either as child or parent of this node
 func (node *DAGNode) AddDependencies(dag *DAG, tags map[string]interface{}, asChild bool) error {
 	var err error
@@ -128,10 +217,15 @@ func (n NodeList) Swap(i, j int) {
 	n[i], n[j] = n[j], n[i]
 }
 
+// The nodes are ordered by weight, then priority, then name.
 func (n NodeList) Less(i, j int) bool {
-	wi := n[i].Weight*DAGDepth + n[i].Priority
-	wj := n[j].Weight*DAGDepth + n[j].Priority
-	return wi < wj || (wi == wj && n[i].Name < n[j].Name)
+	if n[i].Weight != n[j].Weight {
+		return n[i].Weight < n[j].Weight
+	}
+	if n[i].Priority != n[j].Priority {
+		return n[i].Priority < n[j].Priority
+	}
+	return n[i].Name < n[j].Name
 }
 
 type ByMethodPriority []*DAGNode
@@ -154,8 +248,9 @@ func (n ByMethodPriority) Less(i, j int) bool {
 
 // We expect a single thread on the server would handle the DAG creation and traversing. So no mutex for now.
 type DAG struct {
-	NameMap    map[string]*DAGNode // DAGNode name to node mapping.
-	WeightList [DAGDepth]NodeList  // List ordered by DAGNodes' weights. Max of 1000 levels in DAG depth.
+	NameMap     map[string]*DAGNode // DAGNode name to node mapping.
+	WeightList  []NodeList          // The nodes of each weight, i.e. the levels of the DAG.
+	BrokenEdges []*BrokenEdge       // The dependencies removed to break the circles.
 }
 
 func (dag *DAG) Init() {
@@ -163,7 +258,7 @@ func (dag *DAG) Init() {
 }
 
 func (dag *DAG) NewNode(name string, data interface{}) (*DAGNode, error) {
-	node := &DAGNode{name, 0, 0, data, nil, dag}
+	node := &DAGNode{name, 0, 0, data, nil, dag, nil, nil, nil}
 	err := dag.AddNode(node)
 	if err != nil {
 		return nil, err
@@ -172,7 +267,7 @@ func (dag *DAG) NewNode(name string, data interface{}) (*DAGNode, error) {
 }
 
 func (dag *DAG) AddNode(node *DAGNode) error {
-	if node == nil || node.Weight < 0 || node.Weight >= DAGDepth || node.dag != dag {
+	if node == nil || node.Weight < 0 || node.dag != dag {
 		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("adding an invalid DAG Node: %v", node))
 	}
 	if dag.NameMap[node.Name] != nil {
@@ -181,32 +276,41 @@ func (dag *DAG) AddNode(node *DAGNode) error {
 
 	node.dag = dag
 	dag.NameMap[node.Name] = node
+	for len(dag.WeightList) <= node.Weight {
+		dag.WeightList = append(dag.WeightList, nil)
+	}
 	dag.WeightList[node.Weight] = append(dag.WeightList[node.Weight], node)
 	return nil
 }
 
-func (dag *DAG) AdjustNodeWeight(node *DAGNode, newWeight int, depList []*DAGNode) error {
-	if dag.NameMap[node.Name] != node {
-		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("changing the weight of a node that's not found in dag: %v", node))
+// Levels returns the number of levels in the DAG.
+func (dag *DAG) Levels() int {
+	return len(dag.WeightList)
+}
+
+// NodesAt returns the nodes on the level.
+func (dag *DAG) NodesAt(level int) NodeList {
+	if level < 0 || level >= len(dag.WeightList) {
+		return nil
 	}
-	l := dag.WeightList[node.Weight]
-	for i, n := range l {
-		if n.Name == node.Name {
-			l[i] = l[0]
-			dag.WeightList[node.Weight] = l[1:]
-			node.Weight = newWeight
-			dag.WeightList[node.Weight] = append(dag.WeightList[node.Weight], node)
-			return node.AdjustChildrenWeight(depList)
-		}
+	return dag.WeightList[level]
+}
+
+// TopologicalOrder returns all the nodes, every node after the nodes it depends on. The order is stable.
+func (dag *DAG) TopologicalOrder() NodeList {
+	var list NodeList
+	for _, l := range dag.WeightList {
+		list = append(list, l...)
 	}
-	return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("changing the weight of a node that's not found in dag: %v", node))
+	sort.Stable(list)
+	return list
 }
 
 type DAGIterFunc func(previous *DAGNode, current *DAGNode) error
 
 func (dag *DAG) IterateWeight(weight int, f DAGIterFunc) error {
-	if weight >= DAGDepth {
-		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid weight to iterate", weight))
+	if weight < 0 || weight >= len(dag.WeightList) {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid weight to iterate: %d", weight))
 	}
 	l := dag.WeightList[weight]
 	for _, n := range l {
@@ -219,7 +323,7 @@ func (dag *DAG) IterateWeight(weight int, f DAGIterFunc) error {
 }
 
 func (dag *DAG) IterateByWeight(f DAGIterFunc) error {
-	for w := 0; w < DAGDepth; w++ {
+	for w := 0; w < len(dag.WeightList); w++ {
 		err := dag.IterateWeight(w, f)
 		if err != nil {
 			return err
@@ -230,7 +334,7 @@ func (dag *DAG) IterateByWeight(f DAGIterFunc) error {
 
 // sort the dag by priority and name
 func (dag *DAG) Sort() {
-	for w := 0; w < DAGDepth; w++ {
+	for w := range dag.WeightList {
 		sort.Sort(dag.WeightList[w])
 	}
 
@@ -241,18 +345,19 @@ func (dag *DAG) Sort() {
 	dag.IterateByWeight(sortChildren)
 }
 
-func (dag *DAG) CheckWeight() {
+// CheckWeight checks that all the children are heavier than their parents.
+func (dag *DAG) CheckWeight() error {
 	checkChildren := func(previous *DAGNode, current *DAGNode) error {
 		if mqutil.Verbose {
 			fmt.Printf("\nname: %s weight: %d priority: %d, children: \n", current.Name, current.Weight, current.Priority)
 		}
 		ok := current.CheckChildrenWeight()
 		if !ok {
-			panic("bad weight detected")
+			return mqutil.NewError(mqutil.ErrInternal, fmt.Sprintf("bad weight detected on %s", current.ToString()))
 		}
 		return nil
 	}
-	dag.IterateByWeight(checkChildren)
+	return dag.IterateByWeight(checkChildren)
 }
 
 func NewDAG() *DAG {
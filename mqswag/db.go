@@ -311,8 +311,10 @@ func (schema *Schema) Iterate(iterFunc SchemaIterator, context interface{}, swag
 }
 
 type DBEntry struct {
-	Data         map[string]interface{}            // The object itself.
-	Associations map[string]map[string]interface{} // The objects associated with this object. Class to object map.
+	Data         map[string]interface{}            `json:"data"`                   // The object itself.
+	Associations map[string]map[string]interface{} `json:"associations,omitempty"` // The objects associated with this object. Class to object map.
+
+	seq int // The insertion order.
 }
 
 func (entry *DBEntry) Matches(criteria interface{}, associations map[string]map[string]interface{}, matches MatchFunc) bool {
@@ -325,12 +327,25 @@ func (entry *DBEntry) Matches(criteria interface{}, associations map[string]map[
 }
 
 // SchemaDB is our in-memory DB. It is organized around Schemas. Each schema maintains a list of objects that matches
-// the schema. We don't build indexes and do linear search. This keeps the searching flexible for now.
+// the schema. The properties tagged as the keys of the class are indexed, the lookups by the other properties
+// or with other match functions search linearly. The objects returned shouldn't be modified, or the indexes
+// go stale.
 type SchemaDB struct {
 	Name      string
 	Schema    *Schema
 	NoHistory bool
 	Objects   []*DBEntry
+
+	indexes []*dbIndex
+	seq     int
+}
+
+func NewSchemaDB(name string, schema *Schema, keys []string) *SchemaDB {
+	db := &SchemaDB{Name: name, Schema: schema}
+	for _, key := range keys {
+		db.indexes = append(db.indexes, newIndex(key))
+	}
+	return db
 }
 
 // Insert inserts an object into the schema's object list.
@@ -338,13 +353,23 @@ func (db *SchemaDB) Insert(obj interface{}, associations map[string]map[string]i
 	if !db.NoHistory {
 		found := db.Find(obj, associations, mqutil.InterfaceEquals, 1)
 		if len(found) == 0 {
-			dbentry := &DBEntry{obj.(map[string]interface{}), associations}
+			db.seq++
+			dbentry := &DBEntry{Data: obj.(map[string]interface{}), Associations: associations, seq: db.seq}
 			db.Objects = append(db.Objects, dbentry)
+			db.index(dbentry)
 		}
 	}
 	return nil
 }
 
+// entries returns the entries to check against the criteria.
+func (db *SchemaDB) entries(criteria interface{}, matches MatchFunc) []*DBEntry {
+	if candidates, ok := db.candidates(criteria, matches); ok {
+		return candidates
+	}
+	return db.Objects
+}
+
 // MatchFunc checks whether the input criteria and an input object matches.
 type MatchFunc func(criteria interface{}, existing interface{}) bool
 
@@ -354,13 +379,19 @@ func MatchAlways(criteria interface{}, existing interface{}) bool {
 
 // Clone this one but not the objects.
 func (db *SchemaDB) CloneSchema() *SchemaDB {
-	return &SchemaDB{db.Name, db.Schema, db.NoHistory, nil}
+	var keys []string
+	for _, index := range db.indexes {
+		keys = append(keys, index.property)
+	}
+	clone := NewSchemaDB(db.Name, db.Schema, keys)
+	clone.NoHistory = db.NoHistory
+	return clone
 }
 
 // Find finds the specified number of objects that match the input criteria.
 func (db *SchemaDB) Find(criteria interface{}, associations map[string]map[string]interface{}, matches MatchFunc, desiredCount int) []interface{} {
 	var result []interface{}
-	for _, entry := range db.Objects {
+	for _, entry := range db.entries(criteria, matches) {
 		if entry.Matches(criteria, associations, matches) {
 			result = append(result, entry.Data)
 			if desiredCount >= 0 && len(result) >= desiredCount {
@@ -374,18 +405,26 @@ func (db *SchemaDB) Find(criteria interface{}, associations map[string]map[strin
 // Delete deletes the specified number of elements that match the criteria. Input -1 for delete all.
 // Returns the number of elements deleted.
 func (db *SchemaDB) Delete(criteria interface{}, associations map[string]map[string]interface{}, matches MatchFunc, desiredCount int) int {
-	count := 0
-	for i, entry := range db.Objects {
+	deleted := make(map[*DBEntry]bool)
+	for _, entry := range db.entries(criteria, matches) {
 		if entry.Matches(criteria, associations, matches) {
-			db.Objects[i] = db.Objects[count]
-			count++
-			if desiredCount >= 0 && count >= desiredCount {
+			deleted[entry] = true
+			db.unindex(entry)
+			if desiredCount >= 0 && len(deleted) >= desiredCount {
 				break
 			}
 		}
 	}
-	db.Objects = db.Objects[count:]
-	return count
+	if len(deleted) > 0 {
+		var objects []*DBEntry
+		for _, entry := range db.Objects {
+			if !deleted[entry] {
+				objects = append(objects, entry)
+			}
+		}
+		db.Objects = objects
+	}
+	return len(deleted)
 }
 
 // Update finds the matching object, then update with the new one.
@@ -393,13 +432,15 @@ func (db *SchemaDB) Update(criteria interface{}, associations map[string]map[str
 	matches MatchFunc, newObj map[string]interface{}, desiredCount int, patch bool) int {
 
 	count := 0
-	for _, entry := range db.Objects {
+	for _, entry := range db.entries(criteria, matches) {
 		if entry.Matches(criteria, associations, matches) {
+			db.unindex(entry)
 			if patch {
 				mqutil.MapCombine(entry.Data, newObj)
 			} else {
 				entry.Data = newObj
 			}
+			db.index(entry)
 			count++
 			if desiredCount >= 0 && count >= desiredCount {
 				break
@@ -432,7 +473,7 @@ func (db *DB) Init(s *Swagger) {
 		}
 		// Note that schema variable is reused in the loop
 		schemaCopy := schema
-		db.schemas[schemaName] = &SchemaDB{schemaName, (*Schema)(&schemaCopy), false, nil}
+		db.schemas[schemaName] = NewSchemaDB(schemaName, (*Schema)(&schemaCopy), keyProperties(schemaName, (*Schema)(&schemaCopy), s))
 	}
 }
 
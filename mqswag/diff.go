@@ -0,0 +1,436 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// Change is one difference between two versions of a swagger spec.
+type Change struct {
+	Location string `json:"location"` // e.g. "post /pet", "get /pet/findByStatus query param status" or "Pet.name"
+	Message  string `json:"message"`
+	Breaking bool   `json:"breaking"`
+}
+
+func (c *Change) ToString() string {
+	return c.Location + ": " + c.Message
+}
+
+// SpecDiff is the list of the differences between two versions of a swagger spec.
+type SpecDiff struct {
+	Changes []*Change `json:"changes"`
+}
+
+// Breaking returns the changes that break the existing clients.
+func (d *SpecDiff) Breaking() []*Change {
+	var changes []*Change
+	for _, c := range d.Changes {
+		if c.Breaking {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// NonBreaking returns the changes that the existing clients can live with.
+func (d *SpecDiff) NonBreaking() []*Change {
+	var changes []*Change
+	for _, c := range d.Changes {
+		if !c.Breaking {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// usage says where a schema is used. Making a schema stricter breaks the clients that send it,
+// making it looser breaks the clients that receive it.
+type usage int
+
+const (
+	usedInRequest usage = 1 << iota
+	usedInResponse
+	usedInBoth = usedInRequest | usedInResponse
+)
+
+type differ struct {
+	diff *SpecDiff
+}
+
+// changed records a change, which is breaking if it breaks any of the ways the schema is used.
+func (d *differ) changed(location string, use usage, breaksRequest bool, breaksResponse bool, format string, a ...interface{}) {
+	breaking := (use&usedInRequest != 0 && breaksRequest) || (use&usedInResponse != 0 && breaksResponse)
+	d.diff.Changes = append(d.diff.Changes, &Change{location, fmt.Sprintf(format, a...), breaking})
+}
+
+// DiffSpecs compares two versions of a swagger spec.
+func DiffSpecs(oldSwagger *Swagger, newSwagger *Swagger) *SpecDiff {
+	d := &differ{&SpecDiff{}}
+
+	oldOps := oldSwagger.operationMap()
+	newOps := newSwagger.operationMap()
+	for _, key := range unionKeys(oldOps, newOps) {
+		oldOp, inOld := oldOps[key]
+		newOp, inNew := newOps[key]
+		switch {
+		case !inOld:
+			d.changed(key, usedInBoth, false, false, "operation added")
+		case !inNew:
+			d.changed(key, usedInBoth, true, true, "operation removed")
+		default:
+			d.diffOperation(key, oldOp, newOp)
+		}
+	}
+
+	oldUsage := oldSwagger.definitionUsage()
+	newUsage := newSwagger.definitionUsage()
+	for _, name := range unionKeys(oldSwagger.Definitions, newSwagger.Definitions) {
+		oldSchema, inOld := oldSwagger.Definitions[name]
+		newSchema, inNew := newSwagger.Definitions[name]
+		switch {
+		case !inOld:
+			d.changed(name, usedInBoth, false, false, "definition added")
+		case !inNew:
+			// The operations using it have changed, which is where it breaks.
+			d.changed(name, oldUsage[name], false, false, "definition removed")
+		default:
+			use := oldUsage[name] | newUsage[name]
+			if use == 0 {
+				use = usedInBoth
+			}
+			d.diffSchema(name, use, &oldSchema, &newSchema)
+		}
+	}
+	return d.diff
+}
+
+// unionKeys returns the keys of both maps, sorted. The maps must be keyed by strings.
+func unionKeys(a interface{}, b interface{}) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range []interface{}{a, b} {
+		for _, k := range reflect.ValueOf(m).MapKeys() {
+			if !seen[k.String()] {
+				seen[k.String()] = true
+				keys = append(keys, k.String())
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// definitionUsage finds out, for every definition, whether it's used in the requests or the responses.
+func (swagger *Swagger) definitionUsage() map[string]usage {
+	usageMap := make(map[string]usage)
+	var markUsage func(schema *Schema, use usage)
+	markUsage = func(schema *Schema, use usage) {
+		iterFunc := func(swagger *Swagger, schemaName string, schema *Schema, context interface{}) error {
+			if len(schemaName) > 0 && usageMap[schemaName]&use == 0 {
+				usageMap[schemaName] |= use
+				markUsage(schema, use)
+			}
+			return nil
+		}
+		schema.Iterate(iterFunc, nil, swagger, true)
+	}
+	for _, op := range swagger.operationMap() {
+		for _, p := range op.Parameters {
+			if p.In == "body" && p.Schema != nil {
+				markUsage((*Schema)(p.Schema), usedInRequest)
+			}
+		}
+		for _, resp := range operationResponses(op) {
+			if resp.Schema != nil {
+				markUsage((*Schema)(resp.Schema), usedInResponse)
+			}
+		}
+	}
+	return usageMap
+}
+
+// operationResponses returns the responses keyed by the status code, or "default".
+func operationResponses(op *spec.Operation) map[string]*spec.Response {
+	responses := make(map[string]*spec.Response)
+	if op.Responses == nil {
+		return responses
+	}
+	for code, resp := range op.Responses.StatusCodeResponses {
+		r := resp // resp is reused in the loop
+		responses[fmt.Sprint(code)] = &r
+	}
+	if op.Responses.Default != nil {
+		responses["default"] = op.Responses.Default
+	}
+	return responses
+}
+
+func parameterMap(op *spec.Operation) map[string]*spec.Parameter {
+	params := make(map[string]*spec.Parameter)
+	for i := range op.Parameters {
+		p := &op.Parameters[i]
+		if p.In == "body" {
+			params["body"] = p
+		} else {
+			params[p.In+" param "+p.Name] = p
+		}
+	}
+	return params
+}
+
+// parameterSchema turns a non-body parameter into a schema, so that it can be compared like one.
+func parameterSchema(simple spec.SimpleSchema, validations spec.CommonValidations, items *spec.Items) *spec.Schema {
+	schema := &spec.Schema{}
+	if len(simple.Type) > 0 {
+		schema.Type = spec.StringOrArray{simple.Type}
+	}
+	schema.Format = simple.Format
+	schema.WithValidations(validations.Validations())
+	if items != nil {
+		schema.Items = &spec.SchemaOrArray{Schema: parameterSchema(items.SimpleSchema, items.CommonValidations, items.Items)}
+	}
+	return schema
+}
+
+func (d *differ) diffOperation(key string, oldOp *spec.Operation, newOp *spec.Operation) {
+	oldParams := parameterMap(oldOp)
+	newParams := parameterMap(newOp)
+	for _, name := range unionKeys(oldParams, newParams) {
+		location := key + " " + name
+		oldParam, inOld := oldParams[name]
+		newParam, inNew := newParams[name]
+		switch {
+		case !inOld && newParam.Required:
+			d.changed(location, usedInRequest, true, false, "required parameter added")
+		case !inOld:
+			d.changed(location, usedInRequest, false, false, "optional parameter added")
+		case !inNew:
+			d.changed(location, usedInRequest, true, false, "parameter removed")
+		default:
+			if newParam.Required && !oldParam.Required {
+				d.changed(location, usedInRequest, true, false, "parameter is now required")
+			} else if oldParam.Required && !newParam.Required {
+				d.changed(location, usedInRequest, false, false, "parameter is now optional")
+			}
+			if name == "body" {
+				if oldParam.Schema != nil && newParam.Schema != nil {
+					d.diffSchema(location, usedInRequest, oldParam.Schema, newParam.Schema)
+				}
+			} else {
+				d.diffSchema(location, usedInRequest,
+					parameterSchema(oldParam.SimpleSchema, oldParam.CommonValidations, oldParam.Items),
+					parameterSchema(newParam.SimpleSchema, newParam.CommonValidations, newParam.Items))
+			}
+		}
+	}
+
+	oldResponses := operationResponses(oldOp)
+	newResponses := operationResponses(newOp)
+	for _, code := range unionKeys(oldResponses, newResponses) {
+		location := key + " response " + code
+		oldResp, inOld := oldResponses[code]
+		newResp, inNew := newResponses[code]
+		switch {
+		case !inOld:
+			d.changed(location, usedInResponse, false, false, "response added")
+		case !inNew:
+			d.changed(location, usedInResponse, false, true, "response removed")
+		case oldResp.Schema == nil && newResp.Schema != nil:
+			d.changed(location, usedInResponse, false, false, "response body added")
+		case oldResp.Schema != nil && newResp.Schema == nil:
+			d.changed(location, usedInResponse, false, true, "response body removed")
+		case oldResp.Schema != nil:
+			d.diffSchema(location, usedInResponse, oldResp.Schema, newResp.Schema)
+		}
+	}
+}
+
+// typeName is how the type of a schema is shown in the changes.
+func typeName(schema *spec.Schema) string {
+	if tokens := schema.Ref.GetPointer().DecodedTokens(); len(tokens) > 0 {
+		return tokens[len(tokens)-1]
+	}
+	if len(schema.Type) == 0 {
+		return "any"
+	}
+	return strings.Join(schema.Type, ",")
+}
+
+func (d *differ) diffSchema(location string, use usage, oldSchema *spec.Schema, newSchema *spec.Schema) {
+	oldRef := oldSchema.Ref.String()
+	newRef := newSchema.Ref.String()
+	if oldRef != newRef || !jsonEquals(oldSchema.Type, newSchema.Type) {
+		d.changed(location, use, true, true, "type changed from %s to %s", typeName(oldSchema), typeName(newSchema))
+		return
+	}
+	if len(oldRef) > 0 {
+		// The definitions are compared on their own.
+		return
+	}
+	if oldSchema.Format != newSchema.Format {
+		d.changed(location, use, true, true, "format changed from %q to %q", oldSchema.Format, newSchema.Format)
+	}
+	d.diffValidations(location, use, oldSchema.Validations().CommonValidations, newSchema.Validations().CommonValidations)
+
+	if !jsonEquals(oldSchema.AllOf, newSchema.AllOf) {
+		d.changed(location, use, true, true, "allOf changed")
+	}
+
+	oldRequired := make(map[string]bool)
+	for _, r := range oldSchema.Required {
+		oldRequired[r] = true
+	}
+	newRequired := make(map[string]bool)
+	for _, r := range newSchema.Required {
+		newRequired[r] = true
+	}
+	for _, name := range unionKeys(oldSchema.Properties, newSchema.Properties) {
+		propLocation := location + "." + name
+		oldProp, inOld := oldSchema.Properties[name]
+		newProp, inNew := newSchema.Properties[name]
+		switch {
+		case !inOld && newRequired[name]:
+			d.changed(propLocation, use, true, false, "required property added")
+		case !inOld:
+			d.changed(propLocation, use, false, false, "property added")
+		case !inNew:
+			d.changed(propLocation, use, false, true, "property removed")
+		default:
+			if newRequired[name] && !oldRequired[name] {
+				d.changed(propLocation, use, true, false, "property is now required")
+			} else if oldRequired[name] && !newRequired[name] {
+				d.changed(propLocation, use, false, true, "property is now optional")
+			}
+			d.diffSchema(propLocation, use, &oldProp, &newProp)
+		}
+	}
+
+	oldItems := itemSchema(oldSchema)
+	newItems := itemSchema(newSchema)
+	if oldItems != nil && newItems != nil {
+		d.diffSchema(location+"[]", use, oldItems, newItems)
+	} else if oldItems != nil || newItems != nil {
+		d.changed(location+"[]", use, true, true, "items changed")
+	}
+
+	oldAdditional := oldSchema.AdditionalProperties
+	newAdditional := newSchema.AdditionalProperties
+	if oldAdditional != nil && newAdditional != nil && oldAdditional.Schema != nil && newAdditional.Schema != nil {
+		d.diffSchema(location+"{}", use, oldAdditional.Schema, newAdditional.Schema)
+	} else if !jsonEquals(oldAdditional, newAdditional) {
+		d.changed(location, use, true, true, "additionalProperties changed")
+	}
+}
+
+func itemSchema(schema *spec.Schema) *spec.Schema {
+	if schema.Items == nil {
+		return nil
+	}
+	if schema.Items.Schema != nil {
+		return schema.Items.Schema
+	}
+	if len(schema.Items.Schemas) > 0 {
+		return &schema.Items.Schemas[0]
+	}
+	return nil
+}
+
+func boundString(v *float64) string {
+	if v == nil {
+		return "none"
+	}
+	return fmt.Sprint(*v)
+}
+
+func intBound(v *int64) *float64 {
+	if v == nil {
+		return nil
+	}
+	f := float64(*v)
+	return &f
+}
+
+// diffBound compares a limit. For an upper limit, a lower value is stricter; for a lower limit, a higher one is.
+func (d *differ) diffBound(location string, use usage, name string, upper bool, oldValue *float64, newValue *float64) {
+	if (oldValue == nil && newValue == nil) || (oldValue != nil && newValue != nil && *oldValue == *newValue) {
+		return
+	}
+	stricter := newValue != nil && (oldValue == nil || (upper && *newValue < *oldValue) || (!upper && *newValue > *oldValue))
+	d.changed(location, use, stricter, !stricter, "%s changed from %s to %s", name, boundString(oldValue), boundString(newValue))
+}
+
+// diffFlag compares a flag that makes the schema stricter when set.
+func (d *differ) diffFlag(location string, use usage, name string, oldValue bool, newValue bool) {
+	if oldValue != newValue {
+		d.changed(location, use, newValue, oldValue, "%s changed from %t to %t", name, oldValue, newValue)
+	}
+}
+
+func (d *differ) diffValidations(location string, use usage, oldV spec.CommonValidations, newV spec.CommonValidations) {
+	d.diffBound(location, use, "maximum", true, oldV.Maximum, newV.Maximum)
+	d.diffFlag(location, use, "exclusiveMaximum", oldV.ExclusiveMaximum, newV.ExclusiveMaximum)
+	d.diffBound(location, use, "minimum", false, oldV.Minimum, newV.Minimum)
+	d.diffFlag(location, use, "exclusiveMinimum", oldV.ExclusiveMinimum, newV.ExclusiveMinimum)
+	d.diffBound(location, use, "maxLength", true, intBound(oldV.MaxLength), intBound(newV.MaxLength))
+	d.diffBound(location, use, "minLength", false, intBound(oldV.MinLength), intBound(newV.MinLength))
+	d.diffBound(location, use, "maxItems", true, intBound(oldV.MaxItems), intBound(newV.MaxItems))
+	d.diffBound(location, use, "minItems", false, intBound(oldV.MinItems), intBound(newV.MinItems))
+	d.diffFlag(location, use, "uniqueItems", oldV.UniqueItems, newV.UniqueItems)
+
+	if oldV.Pattern != newV.Pattern {
+		// Any new pattern may reject what the old one accepted.
+		d.changed(location, use, len(newV.Pattern) > 0, len(oldV.Pattern) > 0, "pattern changed from %q to %q", oldV.Pattern, newV.Pattern)
+	}
+	if !jsonEquals(oldV.MultipleOf, newV.MultipleOf) {
+		d.changed(location, use, newV.MultipleOf != nil, oldV.MultipleOf != nil, "multipleOf changed from %s to %s",
+			boundString(oldV.MultipleOf), boundString(newV.MultipleOf))
+	}
+	d.diffEnum(location, use, oldV.Enum, newV.Enum)
+}
+
+func (d *differ) diffEnum(location string, use usage, oldEnum []interface{}, newEnum []interface{}) {
+	switch {
+	case len(oldEnum) == 0 && len(newEnum) == 0:
+		return
+	case len(oldEnum) == 0:
+		d.changed(location, use, true, false, "enum added: %s", enumString(newEnum))
+		return
+	case len(newEnum) == 0:
+		d.changed(location, use, false, true, "enum removed")
+		return
+	}
+	if removed := enumMinus(oldEnum, newEnum); len(removed) > 0 {
+		d.changed(location, use, true, false, "enum values removed: %s", enumString(removed))
+	}
+	if added := enumMinus(newEnum, oldEnum); len(added) > 0 {
+		d.changed(location, use, false, true, "enum values added: %s", enumString(added))
+	}
+}
+
+// enumMinus returns the values in a that are not in b.
+func enumMinus(a []interface{}, b []interface{}) []interface{} {
+	var result []interface{}
+	for _, va := range a {
+		found := false
+		for _, vb := range b {
+			if jsonEquals(va, vb) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, va)
+		}
+	}
+	return result
+}
+
+func enumString(values []interface{}) string {
+	valuesBytes, _ := json.Marshal(values)
+	return string(valuesBytes)
+}
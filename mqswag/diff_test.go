@@ -0,0 +1,57 @@
+package mqswag
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func TestDiffSpecs(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	oldSwagger, err := CreateSwaggerFromURL("testdata/diff_old.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSwagger, err := CreateSwaggerFromURL("testdata/diff_new.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{
+		"delete /pet/{petId}: operation removed":                                  true,
+		"get /pet/findByStatus query param limit: maximum changed from 100 to 50": true,
+		"post /pet response 405: response removed":                                true,
+		"Pet.age: type changed from integer to string":                            true,
+		"Pet.tag: property is now required":                                       true,
+		"get /pet/findByStatus query param sort: optional parameter added":        false,
+		"get /pet/findByStatus query param status: enum values added: [\"lost\"]": false,
+		"post /store/order: operation added":                                      false,
+		"Pet.color: property added":                                               false,
+	}
+	specDiff := DiffSpecs(oldSwagger, newSwagger)
+	for _, c := range specDiff.Changes {
+		breaking, ok := expected[c.ToString()]
+		if !ok {
+			t.Errorf("unexpected change %s", c.ToString())
+			continue
+		}
+		if breaking != c.Breaking {
+			t.Errorf("%s: expected breaking to be %t", c.ToString(), breaking)
+		}
+		delete(expected, c.ToString())
+	}
+	for c := range expected {
+		t.Errorf("missing change %s", c)
+	}
+
+	if len(DiffSpecs(oldSwagger, oldSwagger).Changes) != 0 {
+		t.Errorf("a spec should have no changes from itself")
+	}
+	// The other way around, the looser limit and the extra enum value are harmless to requests.
+	for _, c := range DiffSpecs(newSwagger, oldSwagger).Changes {
+		if c.Location == "get /pet/findByStatus query param limit" && c.Breaking {
+			t.Errorf("raising the maximum of a request parameter shouldn't be breaking")
+		}
+	}
+}
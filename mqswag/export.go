@@ -0,0 +1,181 @@
+package mqswag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gbatanov/meqa/mqutil"
+	"github.com/go-openapi/spec"
+)
+
+const (
+	GraphFormatDot     = "dot"
+	GraphFormatMermaid = "mermaid"
+	GraphFormatJson    = "json"
+)
+
+// GraphNode is the exported view of a DAGNode.
+type GraphNode struct {
+	Id          string `json:"id"`
+	Type        string `json:"type"` // definition or operation
+	Name        string `json:"name"` // The definition name, or the path of the operation.
+	Method      string `json:"method,omitempty"`
+	OperationId string `json:"operationId,omitempty"`
+	Weight      int    `json:"weight"`
+	Priority    int    `json:"priority"`
+}
+
+// The label shown in the diagrams.
+func (n *GraphNode) Label() string {
+	if n.Type == "operation" {
+		return strings.ToUpper(n.Method) + " " + n.Name
+	}
+	return n.Name
+}
+
+// GraphEdge points from the parent to the child, i.e. the child depends on the parent.
+type GraphEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Strength string `json:"strength"` // required or optional
+}
+
+// Graph is the exported DAG, with the nodes in the order used to generate the tests. The broken
+// edges are the dependencies ignored to break the circles.
+type Graph struct {
+	Nodes       []*GraphNode `json:"nodes"`
+	Edges       []*GraphEdge `json:"edges"`
+	BrokenEdges []*GraphEdge `json:"brokenEdges,omitempty"`
+}
+
+func newGraphNode(node *DAGNode) *GraphNode {
+	n := &GraphNode{Id: node.Name, Name: node.GetName(), Weight: node.Weight, Priority: node.Priority}
+	if node.GetType() == TypeOp {
+		n.Type = "operation"
+		n.Method = node.GetMethod()
+		if op, ok := node.Data.(*spec.Operation); ok && op != nil {
+			n.OperationId = op.ID
+		}
+	} else {
+		n.Type = "definition"
+	}
+	return n
+}
+
+// Export exports the DAG. If focus is not empty, only the nodes matching focus, together with all the
+// nodes they depend on and all the nodes that depend on them, are exported.
+func (dag *DAG) Export(focus string) (*Graph, error) {
+	nodes := dag.TopologicalOrder()
+	included := make(map[*DAGNode]bool)
+	for _, n := range nodes {
+		if len(focus) == 0 {
+			included[n] = true
+			continue
+		}
+		if !n.Matches(focus) {
+			continue
+		}
+		included[n] = true
+		for _, m := range append(n.Ancestors(), n.Descendants()...) {
+			included[m] = true
+		}
+	}
+	if len(included) == 0 && len(focus) > 0 {
+		return nil, mqutil.NewError(mqutil.ErrNotFound, fmt.Sprintf("no definition or operation matches %s", focus))
+	}
+
+	graph := &Graph{Nodes: []*GraphNode{}, Edges: []*GraphEdge{}}
+	for _, n := range nodes {
+		if !included[n] {
+			continue
+		}
+		graph.Nodes = append(graph.Nodes, newGraphNode(n))
+		children := append(NodeList{}, n.Children...)
+		sort.Sort(children)
+		for _, c := range children {
+			if included[c] {
+				graph.Edges = append(graph.Edges, &GraphEdge{n.Name, c.Name, n.Strength(c).String()})
+			}
+		}
+	}
+	for _, e := range dag.BrokenEdges {
+		if included[e.From] && included[e.To] {
+			graph.BrokenEdges = append(graph.BrokenEdges, &GraphEdge{e.From.Name, e.To.Name, e.Strength.String()})
+		}
+	}
+	return graph, nil
+}
+
+// ToDot returns the graph in the graphviz dot language.
+func (g *Graph) ToDot() string {
+	var b strings.Builder
+	b.WriteString("digraph meqa {\n")
+	b.WriteString("  rankdir=LR;\n")
+	for _, n := range g.Nodes {
+		shape := "ellipse"
+		if n.Type == "operation" {
+			shape = "box"
+		}
+		// %q turns the line break into \n, which is what dot expects.
+		label := fmt.Sprintf("%s\nweight %d, priority %d", n.Label(), n.Weight, n.Priority)
+		b.WriteString(fmt.Sprintf("  %q [label=%q, shape=%s];\n", n.Id, label, shape))
+	}
+	for _, e := range g.Edges {
+		if e.Strength == EdgeRequired.String() {
+			b.WriteString(fmt.Sprintf("  %q -> %q;\n", e.From, e.To))
+		} else {
+			b.WriteString(fmt.Sprintf("  %q -> %q [style=dashed, label=%q];\n", e.From, e.To, e.Strength))
+		}
+	}
+	for _, e := range g.BrokenEdges {
+		b.WriteString(fmt.Sprintf("  %q -> %q [style=dotted, color=red, label=\"broken\"];\n", e.From, e.To))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// ToMermaid returns the graph as a mermaid flowchart.
+func (g *Graph) ToMermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	// Mermaid ids can't have the characters in the node names, so the nodes are numbered.
+	ids := make(map[string]string)
+	for i, n := range g.Nodes {
+		ids[n.Id] = fmt.Sprintf("n%d", i)
+		label := strings.Replace(fmt.Sprintf("%s<br/>weight %d, priority %d", n.Label(), n.Weight, n.Priority), `"`, "#quot;", -1)
+		if n.Type == "operation" {
+			b.WriteString(fmt.Sprintf("  %s[\"%s\"]\n", ids[n.Id], label))
+		} else {
+			b.WriteString(fmt.Sprintf("  %s([\"%s\"])\n", ids[n.Id], label))
+		}
+	}
+	for _, e := range g.Edges {
+		if e.Strength == EdgeRequired.String() {
+			b.WriteString(fmt.Sprintf("  %s --> %s\n", ids[e.From], ids[e.To]))
+		} else {
+			b.WriteString(fmt.Sprintf("  %s -. %s .-> %s\n", ids[e.From], e.Strength, ids[e.To]))
+		}
+	}
+	for _, e := range g.BrokenEdges {
+		b.WriteString(fmt.Sprintf("  %s -. broken .-x %s\n", ids[e.From], ids[e.To]))
+	}
+	return b.String()
+}
+
+// Format returns the graph in one of the GraphFormat formats.
+func (g *Graph) Format(format string) (string, error) {
+	switch format {
+	case GraphFormatDot:
+		return g.ToDot(), nil
+	case GraphFormatMermaid:
+		return g.ToMermaid(), nil
+	case GraphFormatJson:
+		graphBytes, err := mqutil.MarshalJsonIndentNoEscape(g)
+		if err != nil {
+			return "", err
+		}
+		return string(graphBytes), nil
+	}
+	return "", mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("unknown graph format: %s", format))
+}
@@ -0,0 +1,154 @@
+package mqswag
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func exportDAG(t *testing.T) *DAG {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := CreateSwaggerFromURL("testdata/export_meqa.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dag := NewDAG()
+	if err = swagger.AddToDAG(dag); err != nil {
+		t.Fatal(err)
+	}
+	dag.Sort()
+	return dag
+}
+
+func format(t *testing.T, g *Graph, format string) string {
+	output, err := g.Format(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+func TestExport(t *testing.T) {
+	g, err := exportDAG(t).Export("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dot := `digraph meqa {
+  rankdir=LR;
+  "d?Tag?" [label="Tag\nweight 0, priority 0", shape=ellipse];
+  "o?/tags?get" [label="GET /tags\nweight 0, priority 2", shape=box];
+  "o?/pets?post" [label="POST /pets\nweight 1, priority 1", shape=box];
+  "d?Pet?" [label="Pet\nweight 2, priority 0", shape=ellipse];
+  "o?/owners?post" [label="POST /owners\nweight 3, priority 201", shape=box];
+  "d?Owner?" [label="Owner\nweight 4, priority 0", shape=ellipse];
+  "d?Tag?" -> "o?/pets?post";
+  "d?Tag?" -> "d?Pet?";
+  "o?/pets?post" -> "d?Pet?";
+  "d?Pet?" -> "o?/owners?post";
+  "d?Pet?" -> "d?Owner?" [style=dashed, label="optional"];
+  "o?/owners?post" -> "d?Owner?";
+}
+`
+	if output := format(t, g, GraphFormatDot); output != dot {
+		t.Errorf("unexpected dot output:\n%s", output)
+	}
+	mermaid := `flowchart LR
+  n0(["Tag<br/>weight 0, priority 0"])
+  n1["GET /tags<br/>weight 0, priority 2"]
+  n2["POST /pets<br/>weight 1, priority 1"]
+  n3(["Pet<br/>weight 2, priority 0"])
+  n4["POST /owners<br/>weight 3, priority 201"]
+  n5(["Owner<br/>weight 4, priority 0"])
+  n0 --> n2
+  n0 --> n3
+  n2 --> n3
+  n3 --> n4
+  n3 -. optional .-> n5
+  n4 --> n5
+`
+	if output := format(t, g, GraphFormatMermaid); output != mermaid {
+		t.Errorf("unexpected mermaid output:\n%s", output)
+	}
+	if _, err = g.Format("svg"); err == nil {
+		t.Errorf("expected an unknown format to fail")
+	}
+}
+
+func TestExportFocus(t *testing.T) {
+	dag := exportDAG(t)
+
+	// The operation, what it depends on and what depends on it, but not the unrelated GET /tags.
+	g, err := dag.Export("addPet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, n := range g.Nodes {
+		ids = append(ids, n.Id)
+	}
+	expected := []string{"d?Tag?", "o?/pets?post", "d?Pet?", "o?/owners?post", "d?Owner?"}
+	if len(ids) != len(expected) || len(g.Edges) != 6 {
+		t.Fatalf("expected the nodes %v with 6 edges, got %v with %d edges", expected, ids, len(g.Edges))
+	}
+	for i := range ids {
+		if ids[i] != expected[i] {
+			t.Errorf("node %d: expected %s, got %s", i, expected[i], ids[i])
+		}
+	}
+
+	g, err = dag.Export("get /tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	json := `{
+    "nodes": [
+        {
+            "id": "o?/tags?get",
+            "type": "operation",
+            "name": "/tags",
+            "method": "get",
+            "operationId": "listTags",
+            "weight": 0,
+            "priority": 2
+        }
+    ],
+    "edges": []
+}
+`
+	if output := format(t, g, GraphFormatJson); output != json {
+		t.Errorf("unexpected json output:\n%s", output)
+	}
+
+	if _, err = dag.Export("Unknown"); err == nil {
+		t.Errorf("expected a focus matching nothing to fail")
+	}
+}
+
+func TestExportBrokenEdges(t *testing.T) {
+	g := &Graph{
+		Nodes:       []*GraphNode{{Id: "d?A?", Type: "definition", Name: "A"}, {Id: "d?B?", Type: "definition", Name: "B", Weight: 1}},
+		Edges:       []*GraphEdge{{"d?A?", "d?B?", "required"}},
+		BrokenEdges: []*GraphEdge{{"d?B?", "d?A?", "optional"}},
+	}
+	dot := `digraph meqa {
+  rankdir=LR;
+  "d?A?" [label="A\nweight 0, priority 0", shape=ellipse];
+  "d?B?" [label="B\nweight 1, priority 0", shape=ellipse];
+  "d?A?" -> "d?B?";
+  "d?B?" -> "d?A?" [style=dotted, color=red, label="broken"];
+}
+`
+	if output := g.ToDot(); output != dot {
+		t.Errorf("unexpected dot output:\n%s", output)
+	}
+	mermaid := `flowchart LR
+  n0(["A<br/>weight 0, priority 0"])
+  n1(["B<br/>weight 1, priority 0"])
+  n0 --> n1
+  n1 -. broken .-x n0
+`
+	if output := g.ToMermaid(); output != mermaid {
+		t.Errorf("unexpected mermaid output:\n%s", output)
+	}
+}
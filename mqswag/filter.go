@@ -0,0 +1,147 @@
+package mqswag
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// StringList is a flag that takes a comma separated list. The flag can also be repeated.
+type StringList []string
+
+func (l *StringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *StringList) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); len(s) > 0 {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// globMatch matches s against the pattern, where * matches anything but a "/", ** matches anything
+// and ? matches any character but a "/".
+func globMatch(pattern string, s string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	matched, err := regexp.MatchString(b.String(), s)
+	return err == nil && matched
+}
+
+// OperationFilter selects operations by their tags, operationIds, paths and methods. The operationIds
+// and the paths are globs. An operation is selected when, for every kind of criteria given, it matches
+// one of them, and it matches none of the exclusions. An empty filter selects everything.
+type OperationFilter struct {
+	Tags         StringList
+	OperationIds StringList
+	Paths        StringList
+	Methods      StringList
+
+	ExcludeTags         StringList
+	ExcludeOperationIds StringList
+	ExcludePaths        StringList
+	ExcludeMethods      StringList
+}
+
+// RegisterFlags adds the flags that set the filter to the flag set.
+func (f *OperationFilter) RegisterFlags(fs *flag.FlagSet) {
+	fs.Var(&f.Tags, "tags", "only the operations with one of these comma separated tags")
+	fs.Var(&f.OperationIds, "ops", "only the operations whose operationId matches one of these comma separated globs")
+	fs.Var(&f.Paths, "paths", "only the operations whose path matches one of these comma separated globs, e.g. /pet/**")
+	fs.Var(&f.Methods, "methods", "only the operations with one of these comma separated methods")
+	fs.Var(&f.ExcludeTags, "exclude-tags", "leave out the operations with any of these comma separated tags")
+	fs.Var(&f.ExcludeOperationIds, "exclude-ops", "leave out the operations whose operationId matches any of these comma separated globs")
+	fs.Var(&f.ExcludePaths, "exclude-paths", "leave out the operations whose path matches any of these comma separated globs")
+	fs.Var(&f.ExcludeMethods, "exclude-methods", "leave out the operations with any of these comma separated methods")
+}
+
+func (f *OperationFilter) IsEmpty() bool {
+	return f == nil || len(f.Tags)+len(f.OperationIds)+len(f.Paths)+len(f.Methods)+len(f.ExcludeTags)+
+		len(f.ExcludeOperationIds)+len(f.ExcludePaths)+len(f.ExcludeMethods) == 0
+}
+
+func matchAny(patterns []string, values []string, match func(pattern string, value string) bool) bool {
+	for _, p := range patterns {
+		for _, v := range values {
+			if match(p, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matches checks whether the operation is selected. The op can be nil, in which case only the
+// path and the method are checked.
+func (f *OperationFilter) Matches(method string, path string, op *spec.Operation) bool {
+	if f.IsEmpty() {
+		return true
+	}
+	var tags []string
+	var ids []string
+	if op != nil {
+		tags = op.Tags
+		if len(op.ID) > 0 {
+			ids = []string{op.ID}
+		}
+	}
+	equalFold := func(pattern string, value string) bool { return strings.EqualFold(pattern, value) }
+	methods := []string{method}
+	paths := []string{path}
+
+	if (len(f.Tags) > 0 && !matchAny(f.Tags, tags, equalFold)) ||
+		(len(f.OperationIds) > 0 && !matchAny(f.OperationIds, ids, globMatch)) ||
+		(len(f.Paths) > 0 && !matchAny(f.Paths, paths, globMatch)) ||
+		(len(f.Methods) > 0 && !matchAny(f.Methods, methods, equalFold)) {
+		return false
+	}
+	return !matchAny(f.ExcludeTags, tags, equalFold) && !matchAny(f.ExcludeOperationIds, ids, globMatch) &&
+		!matchAny(f.ExcludePaths, paths, globMatch) && !matchAny(f.ExcludeMethods, methods, equalFold)
+}
+
+// MatchesNode checks whether the operation node is selected. Definitions are never selected.
+func (f *OperationFilter) MatchesNode(node *DAGNode) bool {
+	if node.GetType() != TypeOp {
+		return false
+	}
+	op, _ := node.Data.(*spec.Operation)
+	return f.Matches(node.GetMethod(), node.GetName(), op)
+}
+
+// MatchesPath checks whether the operation at the path in the swagger spec is selected.
+func (f *OperationFilter) MatchesPath(swagger *Swagger, method string, path string) bool {
+	if f.IsEmpty() {
+		return true
+	}
+	var op *spec.Operation
+	if swagger != nil && swagger.Paths != nil {
+		if pathItem, ok := swagger.Paths.Paths[path]; ok {
+			if opInterface, err := pathItem.JSONLookup(strings.ToLower(method)); err == nil {
+				op, _ = opInterface.(*spec.Operation)
+			}
+		}
+	}
+	return f.Matches(method, path, op)
+}
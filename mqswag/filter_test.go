@@ -0,0 +1,52 @@
+package mqswag
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/go-openapi/spec"
+)
+
+func TestOperationFilter(t *testing.T) {
+	addPet := &spec.Operation{}
+	addPet.ID = "addPet"
+	addPet.Tags = []string{"pet"}
+	placeOrder := &spec.Operation{}
+	placeOrder.ID = "placeOrder"
+	placeOrder.Tags = []string{"store"}
+
+	var empty *OperationFilter
+	if !empty.Matches("post", "/pet", addPet) {
+		t.Errorf("a nil filter should select everything")
+	}
+
+	filter := &OperationFilter{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	filter.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-tags", "pet,store", "-paths", "/pet/**", "-paths", "/store/*", "-exclude-methods", "DELETE"}); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		method string
+		path   string
+		op     *spec.Operation
+		want   bool
+	}{
+		{"post", "/pet", addPet, false}, // /pet/** needs something after the /
+		{"get", "/pet/{petId}/photos", addPet, true},
+		{"post", "/store/order", placeOrder, true},
+		{"get", "/store/order/{orderId}", placeOrder, false},
+		{"delete", "/pet/{petId}", addPet, false},
+		{"get", "/pet/{petId}", nil, false}, // no tags
+	}
+	for _, c := range cases {
+		if got := filter.Matches(c.method, c.path, c.op); got != c.want {
+			t.Errorf("%s %s: expected %t, got %t", c.method, c.path, c.want, got)
+		}
+	}
+
+	filter = &OperationFilter{OperationIds: StringList{"place*"}, ExcludeTags: StringList{"pet"}}
+	if !filter.Matches("post", "/store/order", placeOrder) || filter.Matches("post", "/pet", addPet) {
+		t.Errorf("the operationId glob should select placeOrder only")
+	}
+}
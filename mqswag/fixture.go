@@ -0,0 +1,80 @@
+package mqswag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+// LoadFixtures adds the objects in the yaml or json file to the DB, for the objects that can't be created
+// through the API. The file maps the definition names to the lists of objects, e.g.
+//
+//	Tenant:
+//	  - id: 1
+//	    name: acme
+//
+// Every object is validated against its definition. The objects it refers to are added too, under their
+// own definitions. Nothing is added if any object is invalid.
+func (db *DB) LoadFixtures(path string) error {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrNotFound, fmt.Sprintf("can't read the fixtures from %s: %s", path, err.Error()))
+	}
+	// Go through json so that the numbers are json.Number, the same as in the responses.
+	jsonBytes, err := mqutil.YamlToJson(fileBytes)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't parse the fixtures in %s: %s", path, err.Error()))
+	}
+	var fixtures map[string][]interface{}
+	d := json.NewDecoder(bytes.NewReader(jsonBytes))
+	d.UseNumber()
+	if err = d.Decode(&fixtures); err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf(
+			"the fixtures in %s should map the definition names to lists of objects: %s", path, err.Error()))
+	}
+
+	var names []string
+	for name := range fixtures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	collection := make(map[string][]interface{})
+	var problems []string
+	for _, name := range names {
+		schema := db.GetSchema(name)
+		if schema == nil {
+			problems = append(problems, fmt.Sprintf("%s: definition not found in the swagger spec", name))
+			continue
+		}
+		for i, obj := range fixtures[name] {
+			if _, ok := obj.(map[string]interface{}); !ok {
+				problems = append(problems, fmt.Sprintf("%s[%d]: not an object", name, i))
+				continue
+			}
+			if err = schema.Parses(name, obj, collection, true, db.Swagger); err != nil {
+				problems = append(problems, fmt.Sprintf("%s[%d]: %s", name, i, err.Error()))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("invalid fixtures in %s:\n%s", path, strings.Join(problems, "\n")))
+	}
+
+	for name, objects := range collection {
+		if db.GetSchema(name) == nil {
+			continue
+		}
+		for _, obj := range objects {
+			if err = db.Insert(name, obj, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := CreateSwaggerFromURL("testdata/diff_old.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &DB{}
+	db.Init(swagger)
+	if err = db.LoadFixtures("testdata/fixtures.yml"); err != nil {
+		t.Fatal(err)
+	}
+	if all := db.Find("Pet", nil, nil, MatchAlways, -1); len(all) != 2 {
+		t.Fatalf("expected 2 pets, got %d", len(all))
+	}
+	// The numbers are the same as in the responses, so the objects can be matched.
+	found := db.Find("Pet", map[string]interface{}{"id": json.Number("100")}, nil, mqutil.InterfaceEquals, -1)
+	if len(found) != 1 || found[0].(map[string]interface{})["name"] != "rex" {
+		t.Errorf("expected rex, got %v", found)
+	}
+
+	invalid := &DB{}
+	invalid.Init(swagger)
+	err = invalid.LoadFixtures("testdata/fixtures_invalid.yml")
+	if err == nil {
+		t.Fatal("expected the invalid fixtures rejected")
+	}
+	for _, problem := range []string{"Pet[0]: ", "required field not present: name", "Tenant: definition not found"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in the error, got %s", problem, err.Error())
+		}
+	}
+	if all := invalid.Find("Pet", nil, nil, MatchAlways, -1); len(all) != 0 {
+		t.Errorf("expected nothing loaded from the invalid fixtures, got %d pets", len(all))
+	}
+}
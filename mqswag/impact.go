@@ -0,0 +1,134 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/spec"
+)
+
+// Matches checks whether the node is the one named. Definitions are named by their names, operations
+// by "method path" (e.g. "post /pet") or their operationId.
+func (node *DAGNode) Matches(name string) bool {
+	if node.GetType() == TypeDef {
+		return node.GetName() == name
+	}
+	if op, ok := node.Data.(*spec.Operation); ok && op != nil && len(op.ID) > 0 && op.ID == name {
+		return true
+	}
+	fields := strings.Fields(name)
+	return len(fields) == 2 && strings.EqualFold(fields[0], node.GetMethod()) && fields[1] == node.GetName()
+}
+
+// OperationKey is how operations are named outside of the DAG, e.g. "post /pet".
+func OperationKey(method string, path string) string {
+	return strings.ToLower(method) + " " + path
+}
+
+// AffectedOperations returns the operations affected by the changed definitions and operations. A changed
+// operation affects itself, a changed definition affects the operations that produce it. Both affect all
+// the operations that depend on them, directly or indirectly. The names that match nothing in the DAG are
+// returned as well.
+func (dag *DAG) AffectedOperations(changed []string) (NodeList, []string) {
+	affected := make(map[*DAGNode]bool)
+	var unmatched []string
+	nodes := dag.TopologicalOrder()
+	for _, name := range changed {
+		found := false
+		for _, n := range nodes {
+			if !n.Matches(name) {
+				continue
+			}
+			found = true
+			related := n.Descendants()
+			if n.GetType() == TypeOp {
+				related = append(related, n)
+			} else {
+				related = append(related, n.Predecessors()...)
+			}
+			for _, m := range related {
+				if m.GetType() == TypeOp {
+					affected[m] = true
+				}
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, name)
+		}
+	}
+
+	var list NodeList
+	for _, n := range nodes {
+		if affected[n] {
+			list = append(list, n)
+		}
+	}
+	return list, unmatched
+}
+
+func jsonEquals(a interface{}, b interface{}) bool {
+	aBytes, _ := json.Marshal(a)
+	bBytes, _ := json.Marshal(b)
+	var aObj, bObj interface{}
+	json.Unmarshal(aBytes, &aObj)
+	json.Unmarshal(bBytes, &bObj)
+	return reflect.DeepEqual(aObj, bObj)
+}
+
+// operationMap returns all the operations, keyed by OperationKey. The path level parameters are
+// added to the operations so that changing them changes the operations.
+func (swagger *Swagger) operationMap() map[string]*spec.Operation {
+	ops := make(map[string]*spec.Operation)
+	if swagger.Paths == nil {
+		return ops
+	}
+	for pathName, pathItem := range swagger.Paths.Paths {
+		for _, method := range MethodAll {
+			opInterface, err := pathItem.JSONLookup(method)
+			if err != nil {
+				continue
+			}
+			op, _ := opInterface.(*spec.Operation)
+			if op == nil {
+				continue
+			}
+			opCopy := *op
+			opCopy.Parameters = append(append([]spec.Parameter{}, pathItem.Parameters...), op.Parameters...)
+			ops[OperationKey(method, pathName)] = &opCopy
+		}
+	}
+	return ops
+}
+
+// ChangedItems compares the two specs and returns the definitions and operations (as "method path")
+// that are added, removed or changed.
+func ChangedItems(oldSwagger *Swagger, newSwagger *Swagger) []string {
+	var defs, ops []string
+	for name, schema := range newSwagger.Definitions {
+		if oldSchema, ok := oldSwagger.Definitions[name]; !ok || !jsonEquals(oldSchema, schema) {
+			defs = append(defs, name)
+		}
+	}
+	for name := range oldSwagger.Definitions {
+		if _, ok := newSwagger.Definitions[name]; !ok {
+			defs = append(defs, name)
+		}
+	}
+	oldOps := oldSwagger.operationMap()
+	newOps := newSwagger.operationMap()
+	for key, op := range newOps {
+		if oldOp, ok := oldOps[key]; !ok || !jsonEquals(oldOp, op) {
+			ops = append(ops, key)
+		}
+	}
+	for key := range oldOps {
+		if _, ok := newOps[key]; !ok {
+			ops = append(ops, key)
+		}
+	}
+	sort.Strings(defs)
+	sort.Strings(ops)
+	return append(defs, ops...)
+}
@@ -0,0 +1,168 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+// dbIndex maps the values of a key property to the entries that have them. The values are hashed the
+// way InterfaceEquals compares them, so an entry can only match criteria hashed the same.
+type dbIndex struct {
+	property string
+	entries  map[string][]*DBEntry
+	unkeyed  []*DBEntry          // The entries whose value can't be hashed, checked for all the criteria.
+	keys     map[*DBEntry]string // The entries indexed to their keys, "" for the unkeyed.
+	numbers  map[*DBEntry]bool   // The entries whose value is a json.Number, which anyNumber criteria match.
+}
+
+func newIndex(property string) *dbIndex {
+	return &dbIndex{property, make(map[string][]*DBEntry), nil, make(map[*DBEntry]string), make(map[*DBEntry]bool)}
+}
+
+// indexKey hashes the value of an entry. Only the simple values are hashed. The time strings aren't,
+// as InterfaceEquals takes the different formats of the same time as equal.
+func indexKey(v interface{}) (string, bool) {
+	switch v.(type) {
+	case bool, json.Number, int, int32, int64, float32, float64:
+	case string:
+		if _, err := time.Parse(time.RFC3339, v.(string)); err == nil {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	keyBytes, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(keyBytes), true
+}
+
+// anyNumber tells whether InterfaceEquals takes the criteria as equal to any json.Number. The other
+// numbers, e.g. the int64 ids the mock parses from the paths, are compared in their JSON form, as hashed.
+func anyNumber(v interface{}) bool {
+	switch v.(type) {
+	case int, float32, float64:
+		return true
+	}
+	return false
+}
+
+func (index *dbIndex) add(entry *DBEntry) {
+	v, ok := entry.Data[index.property]
+	if !ok || v == nil {
+		// Can't match any criteria on the property.
+		return
+	}
+	key, ok := indexKey(v)
+	if ok {
+		index.entries[key] = append(index.entries[key], entry)
+	} else {
+		index.unkeyed = append(index.unkeyed, entry)
+	}
+	index.keys[entry] = key
+	if _, ok := v.(json.Number); ok {
+		index.numbers[entry] = true
+	}
+}
+
+func removeEntry(entries []*DBEntry, entry *DBEntry) []*DBEntry {
+	for i, e := range entries {
+		if e == entry {
+			return append(entries[:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
+
+func (index *dbIndex) remove(entry *DBEntry) {
+	key, ok := index.keys[entry]
+	if !ok {
+		return
+	}
+	delete(index.keys, entry)
+	delete(index.numbers, entry)
+	if len(key) == 0 {
+		index.unkeyed = removeEntry(index.unkeyed, entry)
+		return
+	}
+	index.entries[key] = removeEntry(index.entries[key], entry)
+	if len(index.entries[key]) == 0 {
+		delete(index.entries, key)
+	}
+}
+
+// keyProperties returns the properties of the schema tagged as the keys of its own class, e.g. <meqa Pet.id>
+// on the id of Pet.
+func keyProperties(name string, schema *Schema, swagger *Swagger) []string {
+	var keys []string
+	for propName, prop := range schema.GetProperties(swagger) {
+		if tag := GetMeqaTag(prop.Description); tag != nil && tag.Class == name && tag.Property == propName {
+			keys = append(keys, propName)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isInterfaceEquals(matches MatchFunc) bool {
+	return matches != nil && reflect.ValueOf(matches).Pointer() == reflect.ValueOf(mqutil.InterfaceEquals).Pointer()
+}
+
+// candidates returns the entries that may match the criteria, in the order they were inserted, using the
+// index with the fewest. Returns false if no index can be used, in which case all the entries have to be
+// checked.
+func (db *SchemaDB) candidates(criteria interface{}, matches MatchFunc) ([]*DBEntry, bool) {
+	criteriaMap, ok := criteria.(map[string]interface{})
+	if !ok || len(db.indexes) == 0 || !isInterfaceEquals(matches) {
+		return nil, false
+	}
+	var best []*DBEntry
+	found := false
+	for _, index := range db.indexes {
+		v, ok := criteriaMap[index.property]
+		if !ok || v == nil {
+			continue
+		}
+		key, ok := indexKey(v)
+		if !ok {
+			continue
+		}
+		entries := index.entries[key]
+		numbers := 0
+		if anyNumber(v) {
+			numbers = len(index.numbers)
+		}
+		if !found || len(entries)+numbers+len(index.unkeyed) < len(best) {
+			best = append(append([]*DBEntry{}, entries...), index.unkeyed...)
+			if numbers > 0 {
+				for entry := range index.numbers {
+					if index.keys[entry] != key {
+						best = append(best, entry)
+					}
+				}
+			}
+			found = true
+		}
+	}
+	if found {
+		sort.Slice(best, func(i, j int) bool { return best[i].seq < best[j].seq })
+	}
+	return best, found
+}
+
+func (db *SchemaDB) index(entry *DBEntry) {
+	for _, index := range db.indexes {
+		index.add(entry)
+	}
+}
+
+func (db *SchemaDB) unindex(entry *DBEntry) {
+	for _, index := range db.indexes {
+		index.remove(entry)
+	}
+}
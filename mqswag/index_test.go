@@ -0,0 +1,100 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqutil"
+	"github.com/go-openapi/spec"
+)
+
+func TestKeyProperties(t *testing.T) {
+	schema := &Schema{}
+	schema.Properties = map[string]spec.Schema{
+		"id":    {SchemaProps: spec.SchemaProps{Description: "<meqa Pet.id>"}},
+		"owner": {SchemaProps: spec.SchemaProps{Description: "<meqa User.id>"}},
+		"name":  {},
+	}
+	if keys := keyProperties("Pet", schema, nil); len(keys) != 1 || keys[0] != "id" {
+		t.Errorf("expected only id indexed, got %v", keys)
+	}
+}
+
+func TestSchemaDBIndex(t *testing.T) {
+	db := NewSchemaDB("Pet", &Schema{}, []string{"id"})
+	for i := 0; i < 1000; i++ {
+		db.Insert(map[string]interface{}{"id": json.Number(fmt.Sprint(i)), "name": fmt.Sprintf("pet%d", i%10)}, nil)
+	}
+	db.Insert(map[string]interface{}{"id": 2000, "name": "int id"}, nil)
+	db.Insert(map[string]interface{}{"id": "2020-01-02T03:04:05Z", "name": "time id"}, nil)
+	db.Insert(map[string]interface{}{"name": "no id"}, nil)
+	// Inserting the same object again is a no-op.
+	db.Insert(map[string]interface{}{"id": json.Number("5"), "name": "pet5"}, nil)
+	if len(db.Objects) != 1003 {
+		t.Fatalf("expected 1003 objects, got %d", len(db.Objects))
+	}
+
+	candidates, ok := db.candidates(map[string]interface{}{"id": json.Number("7")}, mqutil.InterfaceEquals)
+	if !ok || len(candidates) != 2 { // pet 7 and the time id, which can't be hashed
+		t.Errorf("expected the index used, got %d candidates", len(candidates))
+	}
+	// An int64 criteria, as the mock parses from the paths, is looked up like the json.Number.
+	if candidates, ok = db.candidates(map[string]interface{}{"id": int64(7)}, mqutil.InterfaceEquals); !ok || len(candidates) != 2 {
+		t.Errorf("expected the index used for an int64, got %d candidates", len(candidates))
+	}
+	// An int criteria matches any json.Number.
+	if candidates, ok = db.candidates(map[string]interface{}{"id": 2000}, mqutil.InterfaceEquals); !ok || len(candidates) != 1002 {
+		t.Errorf("expected the json.Numbers, the int and the time ids as candidates, got %d", len(candidates))
+	}
+	if _, ok = db.candidates(map[string]interface{}{"name": "pet7"}, mqutil.InterfaceEquals); ok {
+		t.Errorf("name isn't indexed")
+	}
+
+	// The results are the same as a linear search.
+	criteria := []interface{}{
+		map[string]interface{}{"id": json.Number("7")},
+		map[string]interface{}{"id": json.Number("2000")},
+		map[string]interface{}{"id": "2020-01-02T03:04:05+00:00"},
+		map[string]interface{}{"id": 3},
+		map[string]interface{}{"id": int64(3)},
+		map[string]interface{}{"id": int64(2000)},
+		map[string]interface{}{"id": 2000.0},
+		map[string]interface{}{"id": json.Number("3"), "name": "pet4"},
+		map[string]interface{}{"name": "pet3"},
+		nil,
+	}
+	for _, c := range criteria {
+		found := db.Find(c, nil, mqutil.InterfaceEquals, -1)
+		var scanned []interface{}
+		for _, entry := range db.Objects {
+			if entry.Matches(c, nil, mqutil.InterfaceEquals) {
+				scanned = append(scanned, entry.Data)
+			}
+		}
+		if fmt.Sprint(found) != fmt.Sprint(scanned) {
+			t.Errorf("criteria %v: the index found %d objects, the scan %d", c, len(found), len(scanned))
+		}
+	}
+
+	// The index follows the updates and the deletes.
+	id7 := map[string]interface{}{"id": json.Number("7")}
+	db.Update(id7, nil, mqutil.InterfaceEquals, map[string]interface{}{"id": json.Number("7000"), "name": "moved"}, 1, false)
+	if found := db.Find(id7, nil, mqutil.InterfaceEquals, -1); len(found) != 0 {
+		t.Errorf("expected pet 7 gone after the update, got %v", found)
+	}
+	id7000 := map[string]interface{}{"id": json.Number("7000")}
+	if found := db.Find(id7000, nil, mqutil.InterfaceEquals, -1); len(found) != 1 {
+		t.Errorf("expected pet 7000 found after the update, got %v", found)
+	}
+	if count := db.Delete(id7000, nil, mqutil.InterfaceEquals, -1); count != 1 || len(db.Objects) != 1002 {
+		t.Errorf("expected pet 7000 deleted, deleted %d", count)
+	}
+	if found := db.Find(id7000, nil, mqutil.InterfaceEquals, -1); len(found) != 0 {
+		t.Errorf("expected pet 7000 gone after the delete, got %v", found)
+	}
+	// The order is kept.
+	if first := db.Find(nil, nil, MatchAlways, 1); first[0].(map[string]interface{})["id"] != json.Number("0") {
+		t.Errorf("expected pet 0 first, got %v", first)
+	}
+}
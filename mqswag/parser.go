@@ -4,6 +4,8 @@ package mqswag
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"sort"
 
 	"os"
 	"path/filepath"
@@ -249,6 +251,45 @@ func (swagger *Swagger) GetSchemaRootType(schema *Schema, parentTag *MeqaTag) (*
 	return nil, nil
 }
 
+// SchemaClass returns the class of the objects the schema holds, and whether the schema is an array of them.
+func (swagger *Swagger) SchemaClass(schema *spec.Schema, desc string) (string, bool) {
+	if schema == nil {
+		return "", false
+	}
+	tag, _ := swagger.GetSchemaRootType((*Schema)(schema), GetMeqaTag(desc))
+	if tag == nil || len(tag.Class) == 0 {
+		return "", false
+	}
+	current := (*Schema)(schema)
+	for {
+		_, referred, err := swagger.GetReferredSchema(current)
+		if err != nil || referred == nil {
+			break
+		}
+		current = referred
+	}
+	return tag.Class, current.Type.Contains(gojsonschema.TYPE_ARRAY)
+}
+
+// SuccessResponse returns the lowest 2xx response of the operation, or the default one.
+func SuccessResponse(op *spec.Operation) (int, *spec.Response) {
+	if op.Responses == nil {
+		return http.StatusOK, nil
+	}
+	var codes []int
+	for code := range op.Responses.StatusCodeResponses {
+		if code >= 200 && code < 300 {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) > 0 {
+		sort.Ints(codes)
+		resp := op.Responses.StatusCodeResponses[codes[0]]
+		return codes[0], &resp
+	}
+	return http.StatusOK, op.Responses.Default
+}
+
 func GetDAGName(t string, n string, m string) string {
 	return t + FieldSeparator + n + FieldSeparator + m
 }
@@ -497,6 +538,53 @@ func AddOperation(pathName string, pathItem *spec.PathItem, method string, swagg
 	return node.AddDependencies(dag, dep.Consumes, false)
 }
 
+// addReference records that the object depends on the named one, keeping the strongest dependency.
+func addReference(references map[string]EdgeStrength, name string, strength EdgeStrength) {
+	if existing, ok := references[name]; !ok || strength > existing {
+		references[name] = strength
+	}
+}
+
+// collectReferences collects the definitions the schema (of the object name) refers to. The strength
+// of the reference is the strength passed in, weakened by optional properties and weak tags on the way.
+func (schema *Schema) collectReferences(name string, strength EdgeStrength, references map[string]EdgeStrength, swagger *Swagger) {
+	if tag := GetMeqaTag(schema.Description); tag != nil && (tag.Flags&FlagWeak) != 0 {
+		strength = EdgeWeak
+	}
+	if len(schema.AllOf) > 0 {
+		for _, s := range schema.AllOf {
+			((*Schema)(&s)).collectReferences(name, strength, references, swagger)
+		}
+		return
+	}
+	if len(schema.Properties) > 0 {
+		required := make(map[string]bool)
+		for _, r := range schema.Required {
+			required[r] = true
+		}
+		for k, v := range schema.Properties {
+			propStrength := strength
+			if !required[k] && propStrength > EdgeOptional {
+				propStrength = EdgeOptional
+			}
+			((*Schema)(&v)).collectReferences(name, propStrength, references, swagger)
+		}
+		return
+	}
+	// Refs, arrays and additional properties, which are not nested.
+	collectInner := func(swagger *Swagger, schemaName string, schema *Schema, context interface{}) error {
+		if len(schemaName) > 0 && schemaName != name {
+			s := strength
+			if tag := GetMeqaTag(schema.Description); tag != nil && (tag.Flags&FlagWeak) != 0 {
+				s = EdgeWeak
+			}
+			addReference(references, schemaName, s)
+		}
+		return nil
+	}
+	schema.Iterate(collectInner, nil, swagger, true)
+}
+
 func (swagger *Swagger) AddToDAG(dag *DAG) error {
 	// Add all definitions
 	for name, schema := range swagger.Definitions {
@@ -509,16 +597,22 @@ func (swagger *Swagger) AddToDAG(dag *DAG) error {
 	// Add all children
 	for name, schema := range swagger.Definitions {
 		node := dag.NameMap[GetDAGName(TypeDef, name, "")]
-		collections := make(map[string]interface{})
-		collectInner := func(swagger *Swagger, schemaName string, schema *Schema, context interface{}) error {
-			if len(schemaName) > 0 && schemaName != name {
-				collections[schemaName] = 1
+		references := make(map[string]EdgeStrength)
+		((*Schema)(&schema)).collectReferences(name, EdgeRequired, references, swagger)
+		// The inner fields are the parents. The child depends on parents.
+		for parentName, strength := range references {
+			if parent := dag.NameMap[GetDAGName(TypeDef, parentName, "")]; parent != nil {
+				if strength == EdgeWeak {
+					// The weak references don't order the objects, same as when they aren't followed.
+					node.AddWeakRef(parent)
+					continue
+				}
+				err := parent.AddChildWithStrength(node, strength)
+				if err != nil {
+					return err
+				}
 			}
-			return nil
 		}
-		((*Schema)(&schema)).Iterate(collectInner, nil, swagger, false)
-		// The inner fields are the parents. The child depends on parents.
-		node.AddDependencies(dag, collections, false)
 	}
 
 	// Add all operations
@@ -530,6 +624,13 @@ func (swagger *Swagger) AddToDAG(dag *DAG) error {
 			}
 		}
 	}
+	// Circular dependencies are broken at the weakest link, so that we can still order the rest.
+	dag.BrokenEdges = dag.BreakCycles()
+	err := dag.ComputeWeights()
+	if err != nil {
+		return err
+	}
+
 	// set priorities. This can only be done after the above, where all weights for all operations are set.
 	for pathName, pathItem := range swagger.Paths.Paths {
 		for _, method := range MethodAll {
@@ -0,0 +1,103 @@
+package mqswag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+// Snapshot holds the objects of the DB by schema name, so that they can be saved to a file and loaded by
+// a later run.
+type Snapshot struct {
+	Schemas map[string][]*DBEntry `json:"schemas"`
+}
+
+func copyEntries(schemaDB *SchemaDB) []*DBEntry {
+	var entries []*DBEntry
+	for _, entry := range schemaDB.Objects {
+		associations := make(map[string]map[string]interface{})
+		for className, association := range entry.Associations {
+			associations[className] = mqutil.MapCopy(association)
+		}
+		entries = append(entries, &DBEntry{Data: mqutil.MapCopy(entry.Data), Associations: associations})
+	}
+	return entries
+}
+
+// Snapshot returns a copy of all the objects in the DB. The schemas without objects are left out.
+func (db *DB) Snapshot() *Snapshot {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	snapshot := &Snapshot{Schemas: make(map[string][]*DBEntry)}
+	for name, schemaDB := range db.schemas {
+		if entries := copyEntries(schemaDB); len(entries) > 0 {
+			snapshot.Schemas[name] = entries
+		}
+	}
+	return snapshot
+}
+
+// Entries returns a copy of the objects of the schema, with their associations, in the order they were added.
+func (db *DB) Entries(name string) []*DBEntry {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.schemas[name] == nil {
+		return nil
+	}
+	return copyEntries(db.schemas[name])
+}
+
+// Restore adds the objects in the snapshot to the DB, skipping the ones already there. The objects of the
+// schemas not in the swagger spec are dropped with a warning.
+func (db *DB) Restore(snapshot *Snapshot) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for name, entries := range snapshot.Schemas {
+		schemaDB := db.schemas[name]
+		if schemaDB == nil {
+			mqutil.Logger.Printf("warning - dropping %d objects of schema %s, not found in the swagger spec", len(entries), name)
+			continue
+		}
+		for _, entry := range entries {
+			if entry == nil || entry.Data == nil {
+				continue
+			}
+			schemaDB.Insert(entry.Data, entry.Associations)
+		}
+	}
+}
+
+// WriteToFile saves all the objects in the DB to the json file.
+func (db *DB) WriteToFile(path string) error {
+	snapshotBytes, err := mqutil.MarshalJsonIndentNoEscape(db.Snapshot())
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrInternal, fmt.Sprintf("can't marshal the DB: %s", err.Error()))
+	}
+	if err = os.WriteFile(path, snapshotBytes, 0644); err != nil {
+		return mqutil.NewError(mqutil.ErrInternal, fmt.Sprintf("can't write the DB to %s: %s", path, err.Error()))
+	}
+	return nil
+}
+
+// LoadFromFile adds the objects saved by WriteToFile to the DB. The numbers are kept as json.Number, the
+// same as in the responses the objects are compared with.
+func (db *DB) LoadFromFile(path string) error {
+	snapshotBytes, err := os.ReadFile(path)
+	if err != nil {
+		return mqutil.NewError(mqutil.ErrNotFound, fmt.Sprintf("can't read the DB from %s: %s", path, err.Error()))
+	}
+	snapshot := &Snapshot{}
+	d := json.NewDecoder(bytes.NewReader(snapshotBytes))
+	d.UseNumber()
+	if err = d.Decode(snapshot); err != nil {
+		return mqutil.NewError(mqutil.ErrInvalid, fmt.Sprintf("can't parse the DB in %s: %s", path, err.Error()))
+	}
+	db.Restore(snapshot)
+	return nil
+}
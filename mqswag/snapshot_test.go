@@ -0,0 +1,50 @@
+package mqswag
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/gbatanov/meqa/mqutil"
+)
+
+func TestDBSnapshot(t *testing.T) {
+	mqutil.Logger = mqutil.NewLogger(io.Discard)
+	swagger, err := CreateSwaggerFromURL("testdata/diff_old.yml", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &DB{}
+	db.Init(swagger)
+	db.Insert("Pet", map[string]interface{}{"id": json.Number("7"), "name": "rex"},
+		map[string]map[string]interface{}{"Owner": {"id": json.Number("3")}})
+	db.Insert("Pet", map[string]interface{}{"id": json.Number("8"), "name": "tom"}, nil)
+
+	path := filepath.Join(t.TempDir(), "db.json")
+	if err = db.WriteToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := &DB{}
+	restored.Init(swagger)
+	if err = restored.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+	// Loading again doesn't duplicate the objects.
+	if err = restored.LoadFromFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if all := restored.Find("Pet", nil, nil, MatchAlways, -1); len(all) != 2 {
+		t.Fatalf("expected 2 pets, got %d", len(all))
+	}
+	found := restored.Find("Pet", map[string]interface{}{"id": json.Number("7")},
+		map[string]map[string]interface{}{"Owner": {"id": json.Number("3")}}, mqutil.InterfaceEquals, -1)
+	if len(found) != 1 || found[0].(map[string]interface{})["name"] != "rex" {
+		t.Errorf("expected rex with its owner, got %v", found)
+	}
+
+	if err = restored.LoadFromFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("expected an error loading a missing file")
+	}
+}
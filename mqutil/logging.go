@@ -14,6 +14,7 @@ const (
 	Skipped        = "Skipped"
 	SchemaMismatch = "SchemaMismatch"
 	Total          = "Total"
+	Retries        = "Retries" // The requests sent again, not a test result.
 )
 
 // Colors for better logging